@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 审计日志
+// 被拒绝的请求（未认证、没有权限、登录失败）按行追加 JSON 记录，便于用 jq 或日志系统检索
+
+// AuditEntry 一条审计记录
+type AuditEntry struct {
+	Time       time.Time `json:"time"`                //时间
+	RemoteAddr string    `json:"remoteAddr"`          //客户端地址
+	Method     string    `json:"method"`              //HTTP 方法
+	Path       string    `json:"path"`                //请求路径
+	Principal  string    `json:"principal,omitempty"` //用户名或 API key 名称，认证失败时为登录时填写的用户名或空
+	Status     int       `json:"status"`              //返回的 HTTP 状态码
+	Reason     string    `json:"reason"`              //拒绝的原因
+}
+
+// AuditLog 审计日志文件
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// 审计日志，启用认证时打开
+var auditLog *AuditLog
+
+// OpenAuditLog 以追加方式打开审计日志
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log error, %s", err)
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Record 写入一条记录，写入失败时输出到标准输出，不影响请求
+func (l *AuditLog) Record(entry AuditEntry) {
+	data, _ := json.Marshal(entry)
+	if l == nil {
+		fmt.Println("audit:", string(data))
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		fmt.Println("write audit log error:", err, string(data))
+	}
+}
+
+// Close 关闭审计日志
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 接口认证和授权
+// 配置中 auth.enabled 为 true 时，/api/v1 下除登录外的接口和旧接口都需要认证：
+//
+//	Authorization: Bearer <令牌>  用户用 POST /api/v1/auth/login 换取的令牌（JWT HS256）
+//	X-API-Key: <key>              系统间调用，key 的哈希配置在 auth.apiKeys 中
+//
+// 每个用户和 API key 对应一个角色，按角色限制可以调用的接口（见 APIRoute.Roles），admin 可以调用全部接口；
+// 同时对应一个调用链码的身份，交易以该身份签名，只有 admin 可以用请求头 X-Identity 指定其他身份。
+// 认证失败、没有权限和登录失败都写入审计日志。
+
+// 角色
+const (
+	RoleViewer  = "viewer"  //查询业务数据、报表和区块
+	RoleOfficer = "officer" //信贷员，可以新建和修改客户、押品和项目
+	RoleAuditor = "auditor" //审计，可以查询变更历史
+	RoleAdmin   = "admin"   //管理员，可以调用全部接口，包括身份管理
+)
+
+var allRoles = []string{RoleViewer, RoleOfficer, RoleAuditor, RoleAdmin}
+
+// 接口可以使用的角色，admin 总是可以调用
+var (
+	readRoles  = allRoles
+	writeRoles = []string{RoleOfficer, RoleAdmin}
+	auditRoles = []string{RoleAuditor, RoleAdmin}
+	adminRoles = []string{RoleAdmin}
+)
+
+// 未指定 Roles 的接口按 HTTP 方法决定：查询所有角色都可以调用，写交易需要 officer
+func methodRoles(method string) []string {
+	if method == http.MethodGet {
+		return readRoles
+	}
+	return writeRoles
+}
+
+// 令牌的签发者
+const tokenIssuer = "assets-gateway"
+
+// Principal 认证通过的调用方
+type Principal struct {
+	Name     string `json:"name"`     //用户名或 API key 名称
+	Kind     string `json:"kind"`     //user 或 apiKey
+	Role     string `json:"role"`     //角色
+	Identity string `json:"identity"` //调用链码的身份
+}
+
+// 令牌中的声明
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	Identity  string `json:"identity"`
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// LoginRequest 登录
+type LoginRequest struct {
+	Username string `form:"username" json:"username" binding:"required"` //用户名
+	Password string `form:"password" json:"password" binding:"required"` //密码
+}
+
+// 用户名和密码换取令牌
+func login(ctx *gin.Context) {
+	if !cfg.Auth.Enabled {
+		respondErrorCode(ctx, ErrInvalidArgument, "authentication is disabled")
+		return
+	}
+	req := new(LoginRequest)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	user := cfg.user(req.Username)
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		deny(ctx, req.Username, ErrUnauthenticated, "invalid username or password")
+		return
+	}
+
+	principal := user.principal()
+	now := time.Now()
+	expires := now.Add(cfg.Auth.TokenTTL)
+	token, err := signToken(tokenClaims{
+		Subject:   principal.Name,
+		Role:      principal.Role,
+		Identity:  principal.Identity,
+		Issuer:    tokenIssuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondOK(ctx, gin.H{"token": token, "expiresAt": expires, "role": principal.Role, "identity": principal.Identity})
+}
+
+// 当前调用方，未启用认证时为空
+func whoami(ctx *gin.Context) {
+	respondOK(ctx, principal(ctx))
+}
+
+// 认证并检查角色，roles 为接口可以使用的角色；未启用认证时不检查
+func authorize(roles []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.Auth.Enabled {
+			ctx.Next()
+			return
+		}
+		p := principal(ctx)
+		if p == nil {
+			var err error
+			if p, err = authenticate(ctx); err != nil {
+				deny(ctx, "", ErrUnauthenticated, err.Error())
+				return
+			}
+			ctx.Set("principal", p)
+		}
+		if p.Role != RoleAdmin && !contains(roles, p.Role) {
+			deny(ctx, p.Name, ErrAccessDenied, fmt.Sprintf("role %s cannot call %s %s", p.Role, ctx.Request.Method, ctx.FullPath()))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 旧接口按 HTTP 方法授权
+func authorizeMethod(ctx *gin.Context) {
+	authorize(methodRoles(ctx.Request.Method))(ctx)
+}
+
+// 本次请求的调用方，未启用认证时为 nil
+func principal(ctx *gin.Context) *Principal {
+	if p, ok := ctx.Get("principal"); ok {
+		return p.(*Principal)
+	}
+	return nil
+}
+
+// 从请求头中的令牌或 API key 认证调用方
+// 令牌只证明用户身份，角色和链码身份按当前配置确定，删除用户或修改角色后立即生效
+func authenticate(ctx *gin.Context) (*Principal, error) {
+	if key := ctx.GetHeader("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		hash := hex.EncodeToString(sum[:])
+		for _, k := range cfg.Auth.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(strings.ToLower(k.KeyHash)), []byte(hash)) == 1 {
+				return k.principal(), nil
+			}
+		}
+		return nil, errors.New("invalid api key")
+	}
+
+	header := ctx.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, errors.New("authorization token or api key is required")
+	}
+	claims, err := parseToken(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+	user := cfg.user(claims.Subject)
+	if user == nil {
+		return nil, fmt.Errorf("user %q no longer exists", claims.Subject)
+	}
+	return user.principal(), nil
+}
+
+// 拒绝请求并写入审计日志
+func deny(ctx *gin.Context, name string, code ErrorCode, reason string) {
+	auditLog.Record(AuditEntry{
+		Time:       time.Now(),
+		RemoteAddr: ctx.ClientIP(),
+		Method:     ctx.Request.Method,
+		Path:       ctx.Request.URL.Path,
+		Principal:  name,
+		Status:     code.HTTPStatus(),
+		Reason:     reason,
+	})
+	respondErrorCode(ctx, code, "%s", reason)
+}
+
+func (c *Config) user(name string) *UserConfig {
+	for i := range c.Auth.Users {
+		if c.Auth.Users[i].Name == name {
+			return &c.Auth.Users[i]
+		}
+	}
+	return nil
+}
+
+func (u *UserConfig) principal() *Principal {
+	identity := u.Identity
+	if identity == "" {
+		identity = u.Name
+	}
+	return &Principal{Name: u.Name, Kind: "user", Role: u.Role, Identity: identity}
+}
+
+func (k *APIKeyConfig) principal() *Principal {
+	identity := k.Identity
+	if identity == "" {
+		identity = cfg.Default.Identity
+	}
+	return &Principal{Name: k.Name, Kind: "apiKey", Role: k.Role, Identity: identity}
+}
+
+// 令牌为 JWT，使用 HS256 签名
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func signToken(claims tokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + tokenSignature(signingInput), nil
+}
+
+// 校验令牌的签名、签发者和有效期
+func parseToken(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, errors.New("malformed token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(tokenSignature(parts[0]+"."+parts[1]))) {
+		return nil, errors.New("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	claims := new(tokenClaims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, errors.New("malformed token")
+	}
+	if claims.Issuer != tokenIssuer {
+		return nil, errors.New("invalid token issuer")
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	return claims, nil
+}
+
+func tokenSignature(signingInput string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Auth.Secret))
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPassword = "s3cret-pass"
+	testAPIKey   = "test-api-key"
+)
+
+// 启用认证的路由：viewer 和 officer 两个用户，一个 officer 的 API key，被拒绝的请求写入临时审计日志
+func testAuthEngine(t *testing.T) (*gin.Engine, string) {
+	engine := testEngine(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHash := sha256.Sum256([]byte(testAPIKey))
+	cfg.Auth = AuthConfig{
+		Enabled:  true,
+		Secret:   strings.Repeat("k", 32),
+		TokenTTL: time.Hour,
+		Users: []UserConfig{
+			{Name: "viewer1", Password: string(hash), Role: RoleViewer, Identity: "admin"},
+			{Name: "officer1", Password: string(hash), Role: RoleOfficer, Identity: "admin"},
+		},
+		APIKeys: []APIKeyConfig{{Name: "batch", KeyHash: hex.EncodeToString(keyHash[:]), Role: RoleOfficer}},
+	}
+
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "audit.log")
+	if auditLog, err = OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	return engine, path
+}
+
+// 关闭审计日志并返回其中的记录
+func closeAuditLog(t *testing.T, path string) []AuditEntry {
+	auditLog.Close()
+	auditLog = nil
+	defer os.RemoveAll(filepath.Dir(path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func bearer(req *http.Request, token string) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func testToken(t *testing.T, name, role string, expires time.Time) string {
+	token, err := signToken(tokenClaims{
+		Subject:   name,
+		Role:      role,
+		Identity:  "admin",
+		Issuer:    tokenIssuer,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// 登录换取的令牌可以调用接口，密码错误时拒绝
+func TestLogin(t *testing.T) {
+	engine, path := testAuthEngine(t)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", apiV1+"/auth/login", strings.NewReader("username=viewer1&password="+testPassword))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	engine.ServeHTTP(w, req)
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Data.Token == "" {
+		t.Fatalf("login = %d %s", w.Code, w.Body.String())
+	}
+	if status, code := serve(engine, bearer(httptest.NewRequest("GET", apiV1+"/auth/me", nil), resp.Data.Token)); status != http.StatusOK {
+		t.Errorf("GET /auth/me with login token = %d %s", status, code)
+	}
+
+	req = httptest.NewRequest("POST", apiV1+"/auth/login", strings.NewReader("username=viewer1&password=wrong"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if status, code := serve(engine, req); status != http.StatusUnauthorized || code != ErrUnauthenticated {
+		t.Errorf("login with wrong password = %d %s, want 401 %s", status, code, ErrUnauthenticated)
+	}
+
+	entries := closeAuditLog(t, path)
+	if len(entries) != 1 || entries[0].Principal != "viewer1" || entries[0].Status != http.StatusUnauthorized {
+		t.Errorf("audit log = %+v, want one failed login for viewer1", entries)
+	}
+}
+
+// 过期、被篡改、算法不对的令牌和未知的 API key 都返回 401 并写入审计日志
+func TestAuthenticationRejected(t *testing.T) {
+	engine, path := testAuthEngine(t)
+
+	valid := testToken(t, "viewer1", RoleViewer, time.Now().Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	// 把 viewer1 改为 officer1，签名不变
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), "viewer1", "officer1", 1))) + "." + parts[2]
+	// 声明为 none 算法，签名为空
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+	// 声明为 HS512 算法，签名按 HS256 计算
+	hs512Input := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS512","typ":"JWT"}`)) + "." + parts[1]
+	hs512 := hs512Input + "." + tokenSignature(hs512Input)
+
+	requests := map[string]*http.Request{
+		"expired token":   bearer(httptest.NewRequest("GET", apiV1+"/auth/me", nil), testToken(t, "viewer1", RoleViewer, time.Now().Add(-time.Minute))),
+		"tampered token":  bearer(httptest.NewRequest("GET", apiV1+"/auth/me", nil), tampered),
+		"alg none token":  bearer(httptest.NewRequest("GET", apiV1+"/auth/me", nil), none),
+		"alg HS512 token": bearer(httptest.NewRequest("GET", apiV1+"/auth/me", nil), hs512),
+		"no credentials":  httptest.NewRequest("GET", apiV1+"/auth/me", nil),
+	}
+	unknownKey := httptest.NewRequest("GET", apiV1+"/auth/me", nil)
+	unknownKey.Header.Set("X-API-Key", "unknown-key")
+	requests["unknown api key"] = unknownKey
+
+	for name, req := range requests {
+		if status, code := serve(engine, req); status != http.StatusUnauthorized || code != ErrUnauthenticated {
+			t.Errorf("%s = %d %s, want 401 %s", name, status, code, ErrUnauthenticated)
+		}
+	}
+
+	key := httptest.NewRequest("GET", apiV1+"/auth/me", nil)
+	key.Header.Set("X-API-Key", testAPIKey)
+	if status, code := serve(engine, key); status != http.StatusOK {
+		t.Errorf("configured api key = %d %s, want 200", status, code)
+	}
+
+	entries := closeAuditLog(t, path)
+	if len(entries) != len(requests) {
+		t.Errorf("audit log has %d entries, want %d", len(entries), len(requests))
+	}
+}
+
+// 角色不能调用的接口返回 403 并写入审计日志
+func TestRoleDenied(t *testing.T) {
+	engine, path := testAuthEngine(t)
+	viewer := testToken(t, "viewer1", RoleViewer, time.Now().Add(time.Hour))
+	officer := testToken(t, "officer1", RoleOfficer, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		req   *http.Request
+	}{
+		{"viewer1", viewer, httptest.NewRequest("POST", apiV1+"/customers", nil)},
+		{"viewer1", viewer, httptest.NewRequest("DELETE", apiV1+"/customers/c1", nil)},
+		{"officer1", officer, httptest.NewRequest("GET", apiV1+"/customers/c1/history", nil)},
+		{"officer1", officer, httptest.NewRequest("GET", apiV1+"/identities", nil)},
+	}
+	for _, tt := range tests {
+		if status, code := serve(engine, bearer(tt.req, tt.token)); status != http.StatusForbidden || code != ErrAccessDenied {
+			t.Errorf("%s %s %s = %d %s, want 403 %s", tt.name, tt.req.Method, tt.req.URL.Path, status, code, ErrAccessDenied)
+		}
+	}
+
+	entries := closeAuditLog(t, path)
+	if len(entries) != len(tests) {
+		t.Fatalf("audit log has %d entries, want %d", len(entries), len(tests))
+	}
+	for i, entry := range entries {
+		if entry.Principal != tests[i].name || entry.Path != tests[i].req.URL.Path || entry.Status != http.StatusForbidden {
+			t.Errorf("audit entry %d = %+v", i, entry)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Block 解析后的区块
+type Block struct {
+	Number       uint64         `json:"number"`       //区块号
+	DataHash     string         `json:"dataHash"`     //区块数据哈希
+	PreviousHash string         `json:"previousHash"` //前一个区块的哈希
+	Transactions []*Transaction `json:"transactions"` //区块中的交易
+}
+
+// Transaction 解析后的交易
+type Transaction struct {
+	TxID           string            `json:"txId"`           //交易id
+	ChannelID      string            `json:"channelId"`      //通道
+	Type           string            `json:"type"`           //交易类型，如 ENDORSER_TRANSACTION、CONFIG
+	Timestamp      time.Time         `json:"timestamp"`      //客户端发起交易的时间
+	CreatorMSP     string            `json:"creatorMsp"`     //提交者所属组织
+	ValidationCode string            `json:"validationCode"` //验证结果，VALID 表示交易有效
+	Valid          bool              `json:"valid"`          //交易是否有效
+	ChaincodeName  string            `json:"chaincodeName"`  //调用的链码名称
+	Function       string            `json:"function"`       //调用的链码函数
+	Args           []string          `json:"args"`           //调用参数，不含函数名
+	Events         []*ChaincodeEvent `json:"events"`         //链码事件
+	Reads          []*KVRead         `json:"reads"`          //读集
+	Writes         []*KVWrite        `json:"writes"`         //写集
+}
+
+// KVRead 交易读集中的一个键
+type KVRead struct {
+	Namespace string   `json:"namespace"` //命名空间，即链码名称
+	Key       string   `json:"key"`       //键
+	Version   *Version `json:"version"`   //读到的版本，键不存在时为 null
+}
+
+// Version 键的版本，即最后写入该键的交易所在的位置
+type Version struct {
+	BlockNumber uint64 `json:"blockNumber"` //区块号
+	TxNumber    uint64 `json:"txNumber"`    //交易在区块中的序号
+}
+
+// KVWrite 交易写集中的一个键
+type KVWrite struct {
+	Namespace string    `json:"namespace"` //命名空间，即链码名称
+	Key       string    `json:"key"`       //键
+	Value     TextBytes `json:"value"`     //写入的值
+	IsDelete  bool      `json:"isDelete"`  //是否为删除
+}
+
+// ChaincodeEvent 交易中的链码事件
+type ChaincodeEvent struct {
+	BlockNumber uint64    `json:"blockNumber"` //区块号
+	TxID        string    `json:"txId"`        //交易id
+	ChaincodeID string    `json:"chaincodeId"` //链码名称
+	EventName   string    `json:"eventName"`   //事件名称
+	Payload     TextBytes `json:"payload"`     //事件内容
+}
+
+// TextBytes 链码写入的值和事件内容，一般为 JSON 文本，转换为 JSON 时按字符串输出而不是 base64
+type TextBytes []byte
+
+// MarshalJSON 实现 json.Marshaler
+func (b TextBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(b))
+}
+
+// 解析区块，区块中的每个交易按顺序解析出交易头、验证结果、链码调用、链码事件和读写集
+func decodeBlock(block *common.Block) (*Block, error) {
+	if block.Header == nil || block.Data == nil {
+		return nil, fmt.Errorf("invalid block, missing header or data")
+	}
+	decoded := &Block{
+		Number:       block.Header.Number,
+		DataHash:     hex.EncodeToString(block.Header.DataHash),
+		PreviousHash: hex.EncodeToString(block.Header.PreviousHash),
+		Transactions: []*Transaction{},
+	}
+
+	// 交易验证结果记录在区块元数据中，与交易一一对应
+	var txFilter []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	for i, data := range block.Data.Data {
+		tx, err := decodeTransaction(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode transaction %d of block %d error, %s", i, decoded.Number, err)
+		}
+		code := peer.TxValidationCode_VALID
+		if i < len(txFilter) {
+			code = peer.TxValidationCode(txFilter[i])
+		}
+		tx.ValidationCode = code.String()
+		tx.Valid = code == peer.TxValidationCode_VALID
+		for _, evt := range tx.Events {
+			evt.BlockNumber = decoded.Number
+		}
+		decoded.Transactions = append(decoded.Transactions, tx)
+	}
+	return decoded, nil
+}
+
+// 解析交易信封
+func decodeTransaction(data []byte) (*Transaction, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("unmarshal envelope error, %s", err)
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, fmt.Errorf("unmarshal payload error, %s", err)
+	}
+	if payload.Header == nil {
+		return nil, fmt.Errorf("missing payload header")
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, channelHeader); err != nil {
+		return nil, fmt.Errorf("unmarshal channel header error, %s", err)
+	}
+	signatureHeader := &common.SignatureHeader{}
+	if err := proto.Unmarshal(payload.Header.SignatureHeader, signatureHeader); err != nil {
+		return nil, fmt.Errorf("unmarshal signature header error, %s", err)
+	}
+	creator := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(signatureHeader.Creator, creator); err != nil {
+		return nil, fmt.Errorf("unmarshal creator error, %s", err)
+	}
+
+	tx := &Transaction{
+		TxID:       channelHeader.TxId,
+		ChannelID:  channelHeader.ChannelId,
+		Type:       common.HeaderType(channelHeader.Type).String(),
+		CreatorMSP: creator.Mspid,
+		Args:       []string{},
+		Events:     []*ChaincodeEvent{},
+		Reads:      []*KVRead{},
+		Writes:     []*KVWrite{},
+	}
+	if channelHeader.Timestamp != nil {
+		tx.Timestamp = time.Unix(channelHeader.Timestamp.Seconds, int64(channelHeader.Timestamp.Nanos)).UTC()
+	}
+
+	// 只有背书交易包含链码调用结果
+	if common.HeaderType(channelHeader.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+		return tx, nil
+	}
+	actions, err := decodeChaincodeActions(payload.Data)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		// 一个交易通常只调用一个链码，取第一个 action 的调用信息
+		if tx.ChaincodeName == "" && action.invocation != nil {
+			tx.ChaincodeName, tx.Function, tx.Args = decodeInvocation(action.invocation)
+		}
+
+		reads, writes, err := decodeRWSet(action.result.Results)
+		if err != nil {
+			return nil, err
+		}
+		tx.Reads = append(tx.Reads, reads...)
+		tx.Writes = append(tx.Writes, writes...)
+
+		if len(action.result.Events) == 0 {
+			continue
+		}
+		ccEvent := &peer.ChaincodeEvent{}
+		if err := proto.Unmarshal(action.result.Events, ccEvent); err != nil {
+			return nil, fmt.Errorf("unmarshal chaincode event error, %s", err)
+		}
+		if ccEvent.EventName == "" {
+			continue
+		}
+		tx.Events = append(tx.Events, &ChaincodeEvent{
+			TxID:        ccEvent.TxId,
+			ChaincodeID: ccEvent.ChaincodeId,
+			EventName:   ccEvent.EventName,
+			Payload:     ccEvent.Payload,
+		})
+	}
+	return tx, nil
+}
+
+// 背书交易中的一个 action：客户端的调用请求和链码执行结果
+type chaincodeAction struct {
+	invocation *peer.ChaincodeInvocationSpec
+	result     *peer.ChaincodeAction
+}
+
+// 解析背书交易中每个 action 的调用请求和链码执行结果
+func decodeChaincodeActions(data []byte) ([]*chaincodeAction, error) {
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(data, transaction); err != nil {
+		return nil, fmt.Errorf("unmarshal transaction error, %s", err)
+	}
+	var actions []*chaincodeAction
+	for _, txAction := range transaction.Actions {
+		actionPayload := &peer.ChaincodeActionPayload{}
+		if err := proto.Unmarshal(txAction.Payload, actionPayload); err != nil {
+			return nil, fmt.Errorf("unmarshal chaincode action payload error, %s", err)
+		}
+		if actionPayload.Action == nil {
+			continue
+		}
+		responsePayload := &peer.ProposalResponsePayload{}
+		if err := proto.Unmarshal(actionPayload.Action.ProposalResponsePayload, responsePayload); err != nil {
+			return nil, fmt.Errorf("unmarshal proposal response payload error, %s", err)
+		}
+		result := &peer.ChaincodeAction{}
+		if err := proto.Unmarshal(responsePayload.Extension, result); err != nil {
+			return nil, fmt.Errorf("unmarshal chaincode action error, %s", err)
+		}
+
+		// 调用请求可能被裁剪，解析失败时只保留执行结果
+		var invocation *peer.ChaincodeInvocationSpec
+		proposalPayload := &peer.ChaincodeProposalPayload{}
+		if err := proto.Unmarshal(actionPayload.ChaincodeProposalPayload, proposalPayload); err == nil {
+			spec := &peer.ChaincodeInvocationSpec{}
+			if err := proto.Unmarshal(proposalPayload.Input, spec); err == nil {
+				invocation = spec
+			}
+		}
+		actions = append(actions, &chaincodeAction{invocation: invocation, result: result})
+	}
+	return actions, nil
+}
+
+// 解析调用请求中的链码名称、函数和参数，参数按文本输出
+func decodeInvocation(spec *peer.ChaincodeInvocationSpec) (name, function string, args []string) {
+	args = []string{}
+	if spec.ChaincodeSpec == nil {
+		return "", "", args
+	}
+	if spec.ChaincodeSpec.ChaincodeId != nil {
+		name = spec.ChaincodeSpec.ChaincodeId.Name
+	}
+	if spec.ChaincodeSpec.Input == nil || len(spec.ChaincodeSpec.Input.Args) == 0 {
+		return name, "", args
+	}
+	function = string(spec.ChaincodeSpec.Input.Args[0])
+	for _, arg := range spec.ChaincodeSpec.Input.Args[1:] {
+		args = append(args, string(arg))
+	}
+	return name, function, args
+}
+
+// 解析链码执行结果中的公有数据读写集
+func decodeRWSet(results []byte) ([]*KVRead, []*KVWrite, error) {
+	if len(results) == 0 {
+		return nil, nil, nil
+	}
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(results, txRWSet); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal read write set error, %s", err)
+	}
+	var reads []*KVRead
+	var writes []*KVWrite
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvRWSet); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal kv read write set of %s error, %s", nsRWSet.Namespace, err)
+		}
+		for _, read := range kvRWSet.Reads {
+			kvRead := &KVRead{Namespace: nsRWSet.Namespace, Key: read.Key}
+			if read.Version != nil {
+				kvRead.Version = &Version{BlockNumber: read.Version.BlockNum, TxNumber: read.Version.TxNum}
+			}
+			reads = append(reads, kvRead)
+		}
+		for _, write := range kvRWSet.Writes {
+			writes = append(writes, &KVWrite{
+				Namespace: nsRWSet.Namespace,
+				Key:       write.Key,
+				Value:     write.Value,
+				IsDelete:  write.IsDelete,
+			})
+		}
+	}
+	return reads, writes, nil
+}
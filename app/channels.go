@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// 通道管理
+// 代替 network/scripts/script.sh 中的 peer channel create/join/update：
+// 用 configtxgen 生成的 channel.tx 创建通道、把节点加入通道、用 <MSP>anchors.tx 更新锚节点、查询节点已加入的通道。
+// 操作使用调用目标的身份（需要是组织管理员，如 org1 的 Admin），节点只能由所属组织的管理员加入通道；
+// 新建的通道需要加入网关配置的 channels 后才能用 X-Channel 调用链码。
+
+// 节点操作结果
+const (
+	PeerOK            = "ok"            //成功
+	PeerAlreadyJoined = "alreadyJoined" //节点已在通道中
+	PeerFailed        = "failed"        //失败，原因见 error
+)
+
+// PeerResult 一个节点的操作结果
+type PeerResult struct {
+	Peer   string    `json:"peer"`            //节点
+	Status string    `json:"status"`          //ok、alreadyJoined、failed
+	Error  *APIError `json:"error,omitempty"` //失败原因
+}
+
+// ChannelCreation 创建通道
+type ChannelCreation struct {
+	Channel   string `form:"channel" json:"channel" binding:"required"` //通道名称，与 channel.tx 中的一致
+	ChannelTx string `form:"channelTx" json:"channelTx"`                //网关所在主机上 channel.tx 的路径，也可以用 multipart 的 file 字段上传
+}
+
+// ChannelJoin 节点加入通道
+type ChannelJoin struct {
+	Channel string   `form:"channel" json:"channel" binding:"required"` //通道名称
+	Peers   []string `form:"peers" json:"peers" binding:"required"`     //节点，SDK 配置文件 peers 下的 key
+}
+
+// AnchorPeersUpdate 更新锚节点
+type AnchorPeersUpdate struct {
+	Channel   string `form:"channel" json:"channel" binding:"required"` //通道名称
+	AnchorsTx string `form:"anchorsTx" json:"anchorsTx"`                //网关所在主机上 <MSP>anchors.tx 的路径，也可以用 multipart 的 file 字段上传
+}
+
+// 创建通道，channelTx 为 configtxgen -outputCreateChannelTx 生成的配置交易
+func createChannel(t *Target, channelID string, channelTx io.Reader) (fab.TransactionID, error) {
+	return saveChannel(t, channelID, channelTx)
+}
+
+// 更新锚节点，anchorsTx 为 configtxgen -outputAnchorPeersUpdate 生成的配置交易
+func updateAnchorPeers(t *Target, channelID string, anchorsTx io.Reader) (fab.TransactionID, error) {
+	return saveChannel(t, channelID, anchorsTx)
+}
+
+// 发送通道配置交易到排序节点
+func saveChannel(t *Target, channelID string, config io.Reader) (fab.TransactionID, error) {
+	cli, err := clients.ResMgmt(t.Org, t.User)
+	if err != nil {
+		return "", err
+	}
+	resp, err := cli.SaveChannel(resmgmt.SaveChannelRequest{
+		ChannelID:     channelID,
+		ChannelConfig: config,
+	}, resmgmt.WithOrdererEndpoint(cfg.Orderer))
+	if err != nil {
+		return "", err
+	}
+	return resp.TransactionID, nil
+}
+
+// 节点逐个加入通道，一个节点失败不影响其他节点
+func joinChannel(t *Target, channelID string, peers []string) []PeerResult {
+	results := make([]PeerResult, 0, len(peers))
+	cli, err := clients.ResMgmt(t.Org, t.User)
+	for _, peer := range peers {
+		result := PeerResult{Peer: peer, Status: PeerOK}
+		if err == nil {
+			err := cli.JoinChannel(channelID, resmgmt.WithTargetEndpoints(peer), resmgmt.WithOrdererEndpoint(cfg.Orderer))
+			switch {
+			case err == nil:
+			case strings.Contains(err.Error(), "already exists"):
+				// 节点上已有该通道的账本
+				result.Status = PeerAlreadyJoined
+			default:
+				result.Status, result.Error = PeerFailed, toAPIError(err)
+			}
+		} else {
+			result.Status, result.Error = PeerFailed, toAPIError(err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 节点已加入的通道
+func joinedChannels(t *Target, peer string) ([]string, error) {
+	cli, err := clients.ResMgmt(t.Org, t.User)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.QueryChannels(resmgmt.WithTargetEndpoints(peer))
+	if err != nil {
+		return nil, err
+	}
+	channels := []string{}
+	for _, channel := range resp.Channels {
+		channels = append(channels, channel.ChannelId)
+	}
+	return channels, nil
+}
+
+// 创建通道
+func createChannelHandler(ctx *gin.Context) {
+	req := new(ChannelCreation)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	channelTx, ok := configTx(ctx, req.ChannelTx, "channelTx")
+	if !ok {
+		return
+	}
+	txID, err := createChannel(target(ctx), req.Channel, channelTx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Response{Code: CodeOK, Message: "success", Data: gin.H{"channel": req.Channel}, TxID: string(txID)})
+}
+
+// 节点加入通道，返回每个节点的结果；有节点失败时 HTTP 状态码为 207
+func joinChannelHandler(ctx *gin.Context) {
+	req := new(ChannelJoin)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	respondPeerResults(ctx, req.Channel, joinChannel(target(ctx), req.Channel, req.Peers))
+}
+
+// 更新锚节点
+func updateAnchorPeersHandler(ctx *gin.Context) {
+	req := new(AnchorPeersUpdate)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	anchorsTx, ok := configTx(ctx, req.AnchorsTx, "anchorsTx")
+	if !ok {
+		return
+	}
+	txID, err := updateAnchorPeers(target(ctx), req.Channel, anchorsTx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Response{Code: CodeOK, Message: "success", Data: gin.H{"channel": req.Channel}, TxID: string(txID)})
+}
+
+// 查询节点已加入的通道
+func joinedChannelsHandler(ctx *gin.Context) {
+	channels, err := joinedChannels(target(ctx), ctx.Param("peer"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondOK(ctx, gin.H{"peer": ctx.Param("peer"), "channels": channels})
+}
+
+// 读取配置交易：优先取上传的 file，否则读取请求体中 field 指定的网关本地文件
+func configTx(ctx *gin.Context, path, field string) (io.Reader, bool) {
+	if file, err := ctx.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			respondError(ctx, err)
+			return nil, false
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			respondError(ctx, err)
+			return nil, false
+		}
+		return bytes.NewReader(data), true
+	}
+	if path == "" {
+		respondErrorCode(ctx, ErrInvalidArgument, "%s or an uploaded file is required", field)
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		respondErrorCode(ctx, ErrInvalidArgument, "%s %s does not exist", field, path)
+		return nil, false
+	}
+	if err != nil {
+		respondError(ctx, fmt.Errorf("read %s error, %s", field, err))
+		return nil, false
+	}
+	return bytes.NewReader(data), true
+}
+
+// 返回各节点的结果，全部成功时为 200，否则为 207，错误码为 PARTIAL_FAILURE
+func respondPeerResults(ctx *gin.Context, channelID string, results []PeerResult) {
+	failed := 0
+	for _, result := range results {
+		if result.Status == PeerFailed {
+			failed++
+		}
+	}
+	data := gin.H{"channel": channelID, "peers": results}
+	if failed == 0 {
+		respondOK(ctx, data)
+		return
+	}
+	ctx.JSON(ErrPartialFailure.HTTPStatus(), Response{
+		Code:    ErrPartialFailure,
+		Message: fmt.Sprintf("%d of %d peers failed", failed, len(results)),
+		Data:    data,
+	})
+}
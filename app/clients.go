@@ -0,0 +1,192 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// 客户端池
+// 创建 SDK 客户端需要加载身份、初始化通道服务和建立连接，开销比一次查询还大，
+// 因此在启动时创建客户端池，按（通道, 组织, 用户）缓存通道上下文和 channel、ledger、event 客户端，按（组织, 用户）缓存 resmgmt 客户端，
+// 按组织缓存 msp 客户端。
+// 客户端可以并发使用；连接失败时调用 Reset 丢弃缓存并关闭该身份的连接，下次使用时重新创建。
+
+// 缓存的键，resmgmt 客户端与通道无关，Channel 为空；msp 客户端使用 CA 配置中的 registrar，Channel 和 User 都为空
+type clientKey struct {
+	Channel string
+	Org     string
+	User    string
+}
+
+func (t *Target) key() clientKey {
+	return clientKey{Channel: t.Channel, Org: t.Org, User: t.User}
+}
+
+// 同一个键下的客户端
+type clientSet struct {
+	context context.Channel
+	channel *channel.Client
+	ledger  *ledger.Client
+	event   *event.Client
+	resmgmt *resmgmt.Client
+	msp     *msp.Client
+}
+
+// ClientPool SDK 客户端池
+type ClientPool struct {
+	sdk *fabsdk.FabricSDK
+
+	mu      sync.Mutex
+	clients map[clientKey]*clientSet
+}
+
+// NewClientPool 创建客户端池，客户端在第一次使用时创建
+func NewClientPool(sdk *fabsdk.FabricSDK) *ClientPool {
+	return &ClientPool{sdk: sdk, clients: map[clientKey]*clientSet{}}
+}
+
+// 取出键对应的客户端，需要持有锁
+func (p *ClientPool) set(key clientKey) *clientSet {
+	set, ok := p.clients[key]
+	if !ok {
+		set = &clientSet{}
+		p.clients[key] = set
+	}
+	return set
+}
+
+// ChannelContext 通道上下文，用于按节点角色过滤节点
+func (p *ClientPool) ChannelContext(t *Target) (context.Channel, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(t.key())
+	if set.context == nil {
+		ctx, err := t.channelContext()()
+		if err != nil {
+			return nil, err
+		}
+		set.context = ctx
+	}
+	return set.context, nil
+}
+
+// Channel 链码调用的客户端
+func (p *ClientPool) Channel(t *Target) (*channel.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(t.key())
+	if set.channel == nil {
+		cli, err := channel.New(t.channelContext())
+		if err != nil {
+			return nil, err
+		}
+		set.channel = cli
+	}
+	return set.channel, nil
+}
+
+// Ledger 账本查询的客户端
+func (p *ClientPool) Ledger(t *Target) (*ledger.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(t.key())
+	if set.ledger == nil {
+		cli, err := ledger.New(t.channelContext())
+		if err != nil {
+			return nil, err
+		}
+		set.ledger = cli
+	}
+	return set.ledger, nil
+}
+
+// Event 事件客户端，接收过滤后的区块事件，用于交易状态和链码事件
+// 监听服务需要从指定区块开始接收完整区块，单独创建客户端，见 listener.go
+func (p *ClientPool) Event(t *Target) (*event.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(t.key())
+	if set.event == nil {
+		cli, err := event.New(t.channelContext())
+		if err != nil {
+			return nil, err
+		}
+		set.event = cli
+	}
+	return set.event, nil
+}
+
+// ResMgmt 资源管理的客户端
+func (p *ClientPool) ResMgmt(org, user string) (*resmgmt.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(clientKey{Org: org, User: user})
+	if set.resmgmt == nil {
+		cli, err := resmgmt.New(p.sdk.Context(fabsdk.WithOrg(org), fabsdk.WithUser(user)))
+		if err != nil {
+			return nil, err
+		}
+		set.resmgmt = cli
+	}
+	return set.resmgmt, nil
+}
+
+// MSP 组织的 CA 客户端，用于注册、登记和吊销用户
+func (p *ClientPool) MSP(org string) (*msp.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	set := p.set(clientKey{Org: org})
+	if set.msp == nil {
+		cli, err := msp.New(p.sdk.Context(), msp.WithOrg(org))
+		if err != nil {
+			return nil, err
+		}
+		set.msp = cli
+	}
+	return set.msp, nil
+}
+
+// Reset 丢弃调用目标的客户端，并关闭该身份在 SDK 中缓存的连接和事件服务
+func (p *ClientPool) Reset(t *Target) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.clients, t.key())
+	delete(p.clients, clientKey{Org: t.Org, User: t.User})
+	if clientCtx, err := p.sdk.Context(fabsdk.WithOrg(t.Org), fabsdk.WithUser(t.User))(); err == nil {
+		p.sdk.CloseContext(clientCtx)
+	}
+}
+
+// ResetIdentity 丢弃身份在所有通道上的客户端并关闭其连接，身份的证书更新或吊销后调用
+func (p *ClientPool) ResetIdentity(org, user string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.clients {
+		if key.Org == org && key.User == user {
+			delete(p.clients, key)
+		}
+	}
+	if clientCtx, err := p.sdk.Context(fabsdk.WithOrg(org), fabsdk.WithUser(user))(); err == nil {
+		p.sdk.CloseContext(clientCtx)
+	}
+}
+
+// Close 丢弃全部客户端并关闭 SDK，之后不能再使用
+func (p *ClientPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clients = map[clientKey]*clientSet{}
+	p.sdk.Close()
+}
+
+// 连接失败或没有可用节点时需要重建客户端
+func isConnectionError(err error) bool {
+	return err != nil && toAPIError(err).Code == ErrPeerUnavailable
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// 网关配置
+// 依次读取配置文件（默认 ./gateway.yaml，不存在时使用默认值）、环境变量和命令行参数，后者覆盖前者。
+// 环境变量和命令行参数只覆盖监听地址、文件路径和默认的通道、链码、身份，多个通道和身份需要在配置文件中配置：
+//
+//	GATEWAY_LISTEN      -listen      监听地址
+//	GATEWAY_SDK_CONFIG  -sdk-config  SDK 配置文件（连接配置）
+//	GATEWAY_CHECKPOINT  -checkpoint  区块事件监听服务的检查点文件
+//	GATEWAY_STORE       -store       链下查询库
+//	GATEWAY_WALLET      -wallet      本地钱包中通过 Fabric CA 登记的身份索引
+//	GATEWAY_CHANNEL     -channel     默认通道，需要在配置文件中
+//	GATEWAY_CHAINCODE   -chaincode   默认链码，需要在默认通道中
+//	GATEWAY_IDENTITY    -identity    默认身份，需要在配置文件中
+//	GATEWAY_ORG         -org         默认身份所属的组织
+//	GATEWAY_USER        -user        默认身份的用户
+//	GATEWAY_PEERS       -peers       默认通道的背书节点，多个用逗号分隔
+//	GATEWAY_ORDERER     -orderer     创建、加入和更新通道时使用的排序节点
+//	GATEWAY_AUTH_SECRET              签发令牌的密钥，只能用环境变量或配置文件指定，避免出现在进程列表中
+
+// Config 网关配置
+type Config struct {
+	Listen     string           `yaml:"listen"`     //监听地址
+	SDKConfig  string           `yaml:"sdkConfig"`  //SDK 配置文件，代码中用到的名字都是该文件中的 key 而不是 value
+	Checkpoint string           `yaml:"checkpoint"` //区块事件监听服务的检查点文件
+	Store      string           `yaml:"store"`      //链下查询库
+	Wallet     string           `yaml:"wallet"`     //本地钱包中的身份索引，证书和私钥在 SDK 配置文件的 credentialStore 中，见 wallet.go
+	Identities []IdentityConfig `yaml:"identities"` //调用链码的身份
+	Channels   []ChannelConfig  `yaml:"channels"`   //通道
+	Orderer    string           `yaml:"orderer"`    //创建、加入和更新通道时使用的排序节点，SDK 配置文件 orderers 下的 key，见 channels.go
+	Default    DefaultConfig    `yaml:"default"`    //请求未指定时使用的通道、链码和身份
+	Auth       AuthConfig       `yaml:"auth"`       //接口认证，见 auth.go
+}
+
+// IdentityConfig 调用链码的身份
+// 链码按证书中的 role 属性控制权限，写交易需要 loanOfficer，历史查询需要 auditor
+type IdentityConfig struct {
+	Name string `yaml:"name"` //身份名称，请求头 X-Identity 中使用
+	Org  string `yaml:"org"`  //所属组织，SDK 配置文件 organizations 下的 key
+	User string `yaml:"user"` //用户名，如 Admin、User1
+}
+
+// ChannelConfig 通道
+type ChannelConfig struct {
+	Name       string   `yaml:"name"`       //通道名称
+	Peers      []string `yaml:"peers"`      //背书和查询的节点，SDK 配置文件 peers 下的 key；discovery 为 true 时只用于账本查询
+	Chaincodes []string `yaml:"chaincodes"` //通道上可以调用的链码
+	Discovery  bool     `yaml:"discovery"`  //是否通过服务发现按链码的背书策略选择背书节点，见 selection.go
+}
+
+// DefaultConfig 默认的通道、链码和身份
+type DefaultConfig struct {
+	Channel   string `yaml:"channel"`
+	Chaincode string `yaml:"chaincode"`
+	Identity  string `yaml:"identity"`
+}
+
+// AuthConfig 接口认证
+// 用户用 /api/v1/auth/login 换取令牌，系统间调用使用 API key；用户和 API key 都对应一个角色和一个调用链码的身份
+type AuthConfig struct {
+	Enabled  bool           `yaml:"enabled"`  //是否启用认证，未启用时任何人都可以调用全部接口
+	Secret   string         `yaml:"secret"`   //签发令牌（JWT HS256）的密钥，至少 32 个字符
+	TokenTTL time.Duration  `yaml:"tokenTTL"` //令牌有效期
+	AuditLog string         `yaml:"auditLog"` //审计日志，记录被拒绝的请求和登录失败
+	Users    []UserConfig   `yaml:"users"`    //可以登录的用户
+	APIKeys  []APIKeyConfig `yaml:"apiKeys"`  //API key
+}
+
+// UserConfig 可以登录的用户
+type UserConfig struct {
+	Name     string `yaml:"name"`     //用户名
+	Password string `yaml:"password"` //bcrypt 哈希，如 htpasswd -bnBC 10 "" <密码> | tr -d ':'
+	Role     string `yaml:"role"`     //viewer、officer、auditor 或 admin
+	Identity string `yaml:"identity"` //调用链码的身份，默认与用户名相同，即通过 /api/v1/identities 登记的同名身份
+}
+
+// APIKeyConfig 系统间调用的 API key，请求头 X-API-Key 中携带
+type APIKeyConfig struct {
+	Name     string `yaml:"name"`     //调用方名称，用于审计日志
+	KeyHash  string `yaml:"keyHash"`  //key 的 SHA-256（十六进制），如 echo -n <key> | sha256sum
+	Role     string `yaml:"role"`     //viewer、officer、auditor 或 admin
+	Identity string `yaml:"identity"` //调用链码的身份，默认为默认身份
+}
+
+// 默认配置，对应 network 目录中的测试网络
+func defaultConfig() *Config {
+	return &Config{
+		Listen:     ":8080",
+		SDKConfig:  "./config.yaml",
+		Checkpoint: "./data/listener.checkpoint",
+		Store:      "./data/assets.db",
+		Wallet:     "./data/wallet/identities.json",
+		Identities: []IdentityConfig{{Name: "admin", Org: "org1", User: "Admin"}},
+		Channels: []ChannelConfig{{
+			Name:       "mychannel",
+			Peers:      []string{"peer0.org1.example.com"},
+			Chaincodes: []string{"assetscc"},
+			Discovery:  true,
+		}},
+		Orderer: "orderer.example.com",
+		Default: DefaultConfig{Channel: "mychannel", Chaincode: "assetscc", Identity: "admin"},
+		Auth:    AuthConfig{TokenTTL: 8 * time.Hour, AuditLog: "./data/audit.log"},
+	}
+}
+
+// 命令行参数
+var (
+	configFileFlag = flag.String("config", "", "网关配置文件，默认为 ./gateway.yaml")
+	listenFlag     = flag.String("listen", "", "监听地址，如 :8080")
+	sdkConfigFlag  = flag.String("sdk-config", "", "SDK 配置文件")
+	checkpointFlag = flag.String("checkpoint", "", "区块事件监听服务的检查点文件")
+	storeFlag      = flag.String("store", "", "链下查询库")
+	walletFlag     = flag.String("wallet", "", "本地钱包中的身份索引")
+	channelFlag    = flag.String("channel", "", "默认通道")
+	chaincodeFlag  = flag.String("chaincode", "", "默认链码")
+	identityFlag   = flag.String("identity", "", "默认身份")
+	orgFlag        = flag.String("org", "", "默认身份所属的组织")
+	userFlag       = flag.String("user", "", "默认身份的用户")
+	peersFlag      = flag.String("peers", "", "默认通道的背书节点，多个用逗号分隔")
+	ordererFlag    = flag.String("orderer", "", "创建、加入和更新通道时使用的排序节点")
+)
+
+// 读取配置，需要在 flag.Parse 之后调用
+func loadConfig() (*Config, error) {
+	c := defaultConfig()
+
+	path := *configFileFlag
+	if path == "" {
+		path = os.Getenv("GATEWAY_CONFIG")
+	}
+	explicit := path != ""
+	if !explicit {
+		path = "./gateway.yaml"
+	}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("parse config file %s error, %s", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+		// 没有配置文件时使用默认配置
+	default:
+		return nil, fmt.Errorf("read config file error, %s", err)
+	}
+
+	// 环境变量，再由命令行参数覆盖
+	for _, source := range []func(name string) string{
+		func(name string) string {
+			return os.Getenv("GATEWAY_" + strings.ToUpper(strings.Replace(name, "-", "_", -1)))
+		},
+		func(name string) string { return flag.Lookup(name).Value.String() },
+	} {
+		c.override(source)
+	}
+	if secret := os.Getenv("GATEWAY_AUTH_SECRET"); secret != "" {
+		c.Auth.Secret = secret
+	}
+	return c, nil
+}
+
+// 用环境变量或命令行参数覆盖配置，value 返回空字符串表示未设置
+func (c *Config) override(value func(name string) string) {
+	set := func(name string, field *string) {
+		if v := value(name); v != "" {
+			*field = v
+		}
+	}
+	set("listen", &c.Listen)
+	set("sdk-config", &c.SDKConfig)
+	set("checkpoint", &c.Checkpoint)
+	set("store", &c.Store)
+	set("wallet", &c.Wallet)
+	set("channel", &c.Default.Channel)
+	set("chaincode", &c.Default.Chaincode)
+	set("identity", &c.Default.Identity)
+	set("orderer", &c.Orderer)
+
+	if identity := c.identity(c.Default.Identity); identity != nil {
+		set("org", &identity.Org)
+		set("user", &identity.User)
+	}
+	if channel := c.channel(c.Default.Channel); channel != nil {
+		if peers := value("peers"); peers != "" {
+			channel.Peers = strings.Split(peers, ",")
+		}
+	}
+}
+
+func (c *Config) identity(name string) *IdentityConfig {
+	for i := range c.Identities {
+		if c.Identities[i].Name == name {
+			return &c.Identities[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) channel(name string) *ChannelConfig {
+	for i := range c.Channels {
+		if c.Channels[i].Name == name {
+			return &c.Channels[i]
+		}
+	}
+	return nil
+}
+
+// Validate 检查配置是否完整，返回全部问题
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if c.Listen == "" {
+		add("listen is required")
+	}
+	if _, err := os.Stat(c.SDKConfig); err != nil {
+		add("sdkConfig %q: %s", c.SDKConfig, err)
+	}
+	if c.Checkpoint == "" {
+		add("checkpoint is required")
+	}
+	if c.Store == "" {
+		add("store is required")
+	}
+	if c.Wallet == "" {
+		add("wallet is required")
+	}
+
+	if len(c.Identities) == 0 {
+		add("at least one identity is required")
+	}
+	identities := map[string]bool{}
+	for i, identity := range c.Identities {
+		if identity.Name == "" || identity.Org == "" || identity.User == "" {
+			add("identities[%d]: name, org and user are required", i)
+		}
+		if identities[identity.Name] {
+			add("identities[%d]: duplicate name %q", i, identity.Name)
+		}
+		identities[identity.Name] = true
+	}
+
+	if len(c.Channels) == 0 {
+		add("at least one channel is required")
+	}
+	if c.Orderer == "" {
+		add("orderer is required")
+	}
+	channels := map[string]bool{}
+	for i, channel := range c.Channels {
+		if channel.Name == "" {
+			add("channels[%d]: name is required", i)
+		}
+		if channels[channel.Name] {
+			add("channels[%d]: duplicate name %q", i, channel.Name)
+		}
+		channels[channel.Name] = true
+		if len(channel.Peers) == 0 {
+			add("channel %q: at least one peer is required", channel.Name)
+		}
+		if len(channel.Chaincodes) == 0 {
+			add("channel %q: at least one chaincode is required", channel.Name)
+		}
+	}
+
+	if !identities[c.Default.Identity] {
+		add("default identity %q is not configured", c.Default.Identity)
+	}
+	if !channels[c.Default.Channel] {
+		add("default channel %q is not configured", c.Default.Channel)
+	} else if !contains(c.channel(c.Default.Channel).Chaincodes, c.Default.Chaincode) {
+		add("default chaincode %q is not configured on channel %q", c.Default.Chaincode, c.Default.Channel)
+	}
+
+	if c.Auth.Enabled {
+		problems = append(problems, c.Auth.validate()...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid gateway config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// 检查认证配置，返回全部问题
+func (a *AuthConfig) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(a.Secret) < 32 {
+		add("auth.secret must be at least 32 characters")
+	}
+	if a.TokenTTL <= 0 {
+		add("auth.tokenTTL must be positive")
+	}
+	if a.AuditLog == "" {
+		add("auth.auditLog is required")
+	}
+	if len(a.Users) == 0 && len(a.APIKeys) == 0 {
+		add("auth: at least one user or api key is required")
+	}
+	users := map[string]bool{}
+	for i, user := range a.Users {
+		if user.Name == "" || user.Password == "" {
+			add("auth.users[%d]: name and password are required", i)
+		}
+		if users[user.Name] {
+			add("auth.users[%d]: duplicate name %q", i, user.Name)
+		}
+		users[user.Name] = true
+		if user.Password != "" && !strings.HasPrefix(user.Password, "$2") {
+			add("auth user %q: password must be a bcrypt hash", user.Name)
+		} else if defaultPassword(user.Password) {
+			add("auth user %q: default password must be changed", user.Name)
+		}
+		if !contains(allRoles, user.Role) {
+			add("auth user %q: unknown role %q", user.Name, user.Role)
+		}
+	}
+	for i, key := range a.APIKeys {
+		if key.Name == "" {
+			add("auth.apiKeys[%d]: name is required", i)
+		}
+		if len(key.KeyHash) != 64 {
+			add("auth api key %q: keyHash must be a hex SHA-256", key.Name)
+		}
+		if !contains(allRoles, key.Role) {
+			add("auth api key %q: unknown role %q", key.Name, key.Role)
+		}
+	}
+	return problems
+}
+
+// 示例配置和网络脚本中使用过的默认密码，用户密码不能是这些
+var defaultPasswords = []string{"adminpw", "admin", "password"}
+
+// 密码哈希是否对应默认密码
+func defaultPassword(hash string) bool {
+	for _, password := range defaultPasswords {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateNetwork 用 SDK 检查配置中的组织、用户和节点是否在 SDK 配置文件中，用户的证书能否加载
+func (c *Config) ValidateNetwork(sdk *fabsdk.FabricSDK) error {
+	var problems []string
+	var endpointConfig fab.EndpointConfig
+	for _, identity := range c.Identities {
+		clientCtx, err := sdk.Context(fabsdk.WithOrg(identity.Org), fabsdk.WithUser(identity.User))()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("identity %q: %s", identity.Name, err))
+			continue
+		}
+		endpointConfig = clientCtx.EndpointConfig()
+		if _, ok := endpointConfig.NetworkConfig().Organizations[strings.ToLower(identity.Org)]; !ok {
+			problems = append(problems, fmt.Sprintf("identity %q: org %q is not in %s", identity.Name, identity.Org, c.SDKConfig))
+		}
+	}
+
+	// 节点配置与身份无关，用任意一个加载成功的身份检查一次
+	if endpointConfig != nil {
+		if _, ok := endpointConfig.OrdererConfig(c.Orderer); !ok {
+			problems = append(problems, fmt.Sprintf("orderer %q is not in %s", c.Orderer, c.SDKConfig))
+		}
+		for _, channel := range c.Channels {
+			for _, peer := range channel.Peers {
+				if _, ok := endpointConfig.PeerConfig(peer); !ok {
+					problems = append(problems, fmt.Sprintf("channel %q: peer %q is not in %s", channel.Name, peer, c.SDKConfig))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("gateway config does not match the network:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Target 一次链码调用的目标
+type Target struct {
+	Channel   string   //通道
+	Chaincode string   //链码
+	Org       string   //身份所属的组织
+	User      string   //用户
+	Peers     []string //背书和查询的节点
+	Discovery bool     //是否通过服务发现选择背书节点
+}
+
+// Target 按名称查找调用目标，名称为空时使用默认值
+// 身份先在配置中查找，没有时使用本地钱包中已登记且未吊销的身份
+func (c *Config) Target(channelName, chaincodeName, identityName string) (*Target, error) {
+	if channelName == "" {
+		channelName = c.Default.Channel
+	}
+	if chaincodeName == "" {
+		chaincodeName = c.Default.Chaincode
+	}
+	if identityName == "" {
+		identityName = c.Default.Identity
+	}
+
+	channel := c.channel(channelName)
+	if channel == nil {
+		return nil, fmt.Errorf("channel %q is not configured", channelName)
+	}
+	if !contains(channel.Chaincodes, chaincodeName) {
+		return nil, fmt.Errorf("chaincode %q is not configured on channel %q", chaincodeName, channelName)
+	}
+	identity := c.identity(identityName)
+	if identity == nil {
+		enrolled, err := wallet.Identity(identityName)
+		if err != nil {
+			return nil, err
+		}
+		identity = enrolled
+	}
+	return &Target{
+		Channel:   channel.Name,
+		Chaincode: chaincodeName,
+		Org:       identity.Org,
+		User:      identity.User,
+		Peers:     channel.Peers,
+		Discovery: channel.Discovery,
+	}, nil
+}
+
+// DefaultTarget 默认的调用目标，配置校验通过后不会出错
+func (c *Config) DefaultTarget() *Target {
+	target, err := c.Target("", "", "")
+	if err != nil {
+		panic(err)
+	}
+	return target
+}
+
+// 调用目标的通道上下文
+func (t *Target) channelContext() context.ChannelProvider {
+	return sdk.ChannelContext(t.Channel, fabsdk.WithOrg(t.Org), fabsdk.WithUser(t.User))
+}
+
+// 账本查询的节点，各节点的区块高度可能不同，只查询第一个节点
+func (t *Target) ledgerPeer() string {
+	return t.Peers[0]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
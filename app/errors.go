@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// ErrorCode 错误码，成功时为 OK
+type ErrorCode string
+
+// 错误码目录
+const (
+	CodeOK ErrorCode = "OK" //成功
+
+	// 链码返回的错误码，与链码 errors.go 中的定义保持一致
+	ErrInvalidArgument     ErrorCode = "INVALID_ARGUMENT"      //参数个数或内容不正确
+	ErrInvalidAmount       ErrorCode = "INVALID_AMOUNT"        //金额格式错误
+	ErrInvalidCurrency     ErrorCode = "INVALID_CURRENCY"      //币种格式错误
+	ErrInvalidDate         ErrorCode = "INVALID_DATE"          //日期格式错误
+	ErrInvalidDateRange    ErrorCode = "INVALID_DATE_RANGE"    //日期先后顺序错误
+	ErrInvalidBoolean      ErrorCode = "INVALID_BOOLEAN"       //是否类字段取值错误
+	ErrInvalidCreditCode   ErrorCode = "INVALID_CREDIT_CODE"   //统一社会信用代码错误
+	ErrCustomerNotFound    ErrorCode = "CUSTOMER_NOT_FOUND"    //客户不存在
+	ErrCustomerExists      ErrorCode = "CUSTOMER_EXISTS"       //客户已存在
+	ErrCustomerArchived    ErrorCode = "CUSTOMER_ARCHIVED"     //客户已归档
+	ErrCollateralNotFound  ErrorCode = "COLLATERAL_NOT_FOUND"  //押品不存在或不处于抵押状态
+	ErrCollateralExists    ErrorCode = "COLLATERAL_EXISTS"     //押品已处于抵押状态
+	ErrProjectExists       ErrorCode = "PROJECT_EXISTS"        //项目已存在
+	ErrDuplicateCreditCode ErrorCode = "DUPLICATE_CREDIT_CODE" //统一社会信用代码已被其他客户使用
+	ErrAccessDenied        ErrorCode = "ACCESS_DENIED"         //没有调用权限
+
+	// 网关自身的错误码
+	ErrUnauthenticated     ErrorCode = "UNAUTHENTICATED"      //未认证，令牌或 API key 缺失、无效或已过期
+	ErrNotFound            ErrorCode = "NOT_FOUND"            //请求的记录不存在
+	ErrChaincode           ErrorCode = "CHAINCODE_ERROR"      //链码返回了非结构化的错误
+	ErrEndorsementMismatch ErrorCode = "ENDORSEMENT_MISMATCH" //各背书节点的执行结果不一致
+	ErrTxInvalid           ErrorCode = "TX_INVALID"           //交易提交后验证未通过，如读写冲突
+	ErrTimeout             ErrorCode = "TIMEOUT"              //请求超时
+	ErrPeerUnavailable     ErrorCode = "PEER_UNAVAILABLE"     //无法连接节点或没有可用的节点
+	ErrUnavailable         ErrorCode = "SERVICE_UNAVAILABLE"  //依赖的服务不可用，如链下查询库
+	ErrCA                  ErrorCode = "CA_ERROR"             //Fabric CA 拒绝了请求，如登记密码错误、用户已注册
+	ErrPartialFailure      ErrorCode = "PARTIAL_FAILURE"      //部分节点操作失败，各节点的结果在 data 中
+	ErrInternal            ErrorCode = "INTERNAL"             //其他错误
+)
+
+// 错误码对应的 HTTP 状态码
+var errorStatus = map[ErrorCode]int{
+	ErrInvalidArgument:     http.StatusBadRequest,
+	ErrInvalidAmount:       http.StatusBadRequest,
+	ErrInvalidCurrency:     http.StatusBadRequest,
+	ErrInvalidDate:         http.StatusBadRequest,
+	ErrInvalidDateRange:    http.StatusBadRequest,
+	ErrInvalidBoolean:      http.StatusBadRequest,
+	ErrInvalidCreditCode:   http.StatusBadRequest,
+	ErrCustomerNotFound:    http.StatusNotFound,
+	ErrCustomerExists:      http.StatusConflict,
+	ErrCustomerArchived:    http.StatusConflict,
+	ErrCollateralNotFound:  http.StatusNotFound,
+	ErrCollateralExists:    http.StatusConflict,
+	ErrProjectExists:       http.StatusConflict,
+	ErrDuplicateCreditCode: http.StatusConflict,
+	ErrAccessDenied:        http.StatusForbidden,
+
+	ErrUnauthenticated:     http.StatusUnauthorized,
+	ErrNotFound:            http.StatusNotFound,
+	ErrChaincode:           http.StatusInternalServerError,
+	ErrEndorsementMismatch: http.StatusBadGateway,
+	ErrTxInvalid:           http.StatusConflict,
+	ErrTimeout:             http.StatusGatewayTimeout,
+	ErrPeerUnavailable:     http.StatusServiceUnavailable,
+	ErrUnavailable:         http.StatusServiceUnavailable,
+	ErrCA:                  http.StatusBadGateway,
+	ErrPartialFailure:      http.StatusMultiStatus,
+	ErrInternal:            http.StatusInternalServerError,
+}
+
+// HTTPStatus 返回错误码对应的 HTTP 状态码，未知错误码按 500 处理
+func (c ErrorCode) HTTPStatus() int {
+	if code, ok := errorStatus[c]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// APIError 接口返回的错误，链码的结构化错误也解析为此类型
+type APIError struct {
+	Code    ErrorCode `json:"code"`            //错误码
+	Field   string    `json:"field,omitempty"` //出错的字段
+	Message string    `json:"message"`         //错误描述
+}
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// 构造错误
+func newAPIError(code ErrorCode, format string, a ...interface{}) *APIError {
+	return &APIError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// 从 SDK 返回的错误中解析链码的结构化错误，不是结构化错误时返回 nil
+func parseChaincodeError(err error) *APIError {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	// 多个背书节点同时报错时，错误信息放在 Details 中
+	messages := []string{s.Message}
+	for _, detail := range s.Details {
+		if detailErr, ok := detail.(error); ok {
+			if ds, ok := status.FromError(detailErr); ok {
+				messages = append(messages, ds.Message)
+			}
+		}
+	}
+
+	for _, message := range messages {
+		i := strings.Index(message, "{")
+		if i < 0 {
+			continue
+		}
+		ccErr := new(APIError)
+		// 错误信息前后可能带有 SDK 附加的内容，用 Decoder 只解析第一个 JSON 对象
+		if err := json.NewDecoder(strings.NewReader(message[i:])).Decode(ccErr); err == nil && ccErr.Code != "" {
+			return ccErr
+		}
+	}
+	return nil
+}
+
+// 把任意错误转换为 APIError
+// 优先使用链码的结构化错误，其次按 SDK 的状态分组和状态码归类
+func toAPIError(err error) *APIError {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr
+	}
+	if ccErr := parseChaincodeError(err); ccErr != nil {
+		return ccErr
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return newAPIError(ErrInternal, "%s", err)
+	}
+	switch {
+	case s.Group == status.ClientStatus && s.Code == status.Timeout.ToInt32():
+		return newAPIError(ErrTimeout, "%s", s.Message)
+	case s.Group == status.EndorserClientStatus && s.Code == status.Timeout.ToInt32():
+		return newAPIError(ErrTimeout, "%s", s.Message)
+	case s.Code == status.EndorsementMismatch.ToInt32() &&
+		(s.Group == status.ClientStatus || s.Group == status.EndorserClientStatus):
+		return newAPIError(ErrEndorsementMismatch, "%s", s.Message)
+	case s.Group == status.EventServerStatus:
+		return newAPIError(ErrTxInvalid, "transaction invalidated with code %s", status.ToTransactionValidationCode(s.Code))
+	case s.Group == status.ChaincodeStatus:
+		return newAPIError(ErrChaincode, "%s", s.Message)
+	case s.Group == status.GRPCTransportStatus,
+		s.Code == status.ConnectionFailed.ToInt32(),
+		s.Code == status.NoPeersFound.ToInt32():
+		return newAPIError(ErrPeerUnavailable, "%s", s.Message)
+	}
+	return newAPIError(ErrInternal, "%s", err)
+}
+
+// Response 统一的响应格式
+type Response struct {
+	Code    ErrorCode   `json:"code"`           //错误码，成功时为 OK
+	Message string      `json:"message"`        //错误描述，成功时为 success
+	Data    interface{} `json:"data,omitempty"` //返回的数据，出错时为出错的字段等详细信息
+	TxID    string      `json:"txId,omitempty"` //写交易的交易id
+
+	Endorsers []string `json:"endorsers,omitempty"` //写交易的背书节点
+}
+
+// 返回数据
+func respondOK(ctx *gin.Context, data interface{}) {
+	ctx.JSON(http.StatusOK, Response{Code: CodeOK, Message: "success", Data: data})
+}
+
+// 返回链码查询结果，链码返回 JSON 时原样作为 data，否则作为字符串
+func respondPayload(ctx *gin.Context, payload []byte) {
+	respondOK(ctx, payloadData(payload))
+}
+
+// 返回写交易的结果，data 为链码的返回值
+func respondTx(ctx *gin.Context, resp channel.Response) {
+	ctx.JSON(http.StatusOK, Response{
+		Code:      CodeOK,
+		Message:   "success",
+		Data:      payloadData(resp.Payload),
+		TxID:      string(resp.TransactionID),
+		Endorsers: endorsers(resp.Responses),
+	})
+}
+
+// 返回已发送到排序节点、尚未上链的写交易，HTTP 状态码为 202，Location 为交易状态的地址
+func respondAccepted(ctx *gin.Context, resp channel.Response) {
+	ctx.Header("Location", apiV1+"/transactions/"+string(resp.TransactionID)+"/status")
+	ctx.JSON(http.StatusAccepted, Response{
+		Code:      CodeOK,
+		Message:   "accepted",
+		Data:      payloadData(resp.Payload),
+		TxID:      string(resp.TransactionID),
+		Endorsers: endorsers(resp.Responses),
+	})
+}
+
+func payloadData(payload []byte) interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+	if json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	return string(payload)
+}
+
+// 返回错误信息，HTTP 状态码由错误码决定
+func respondError(ctx *gin.Context, err error) {
+	respondTxError(ctx, channel.Response{}, err)
+}
+
+// 返回写交易的错误信息，背书完成后出错（排序失败、验证未通过）时附带交易id和背书节点
+func respondTxError(ctx *gin.Context, tx channel.Response, err error) {
+	apiErr := toAPIError(err)
+	resp := Response{Code: apiErr.Code, Message: apiErr.Message}
+	// 背书失败时交易没有发送到排序节点，交易id没有意义
+	if len(tx.Responses) > 0 {
+		resp.TxID, resp.Endorsers = string(tx.TransactionID), endorsers(tx.Responses)
+	}
+	if apiErr.Field != "" {
+		resp.Data = gin.H{"field": apiErr.Field}
+	}
+	ctx.AbortWithStatusJSON(apiErr.Code.HTTPStatus(), resp)
+}
+
+// 返回指定错误码的错误
+func respondErrorCode(ctx *gin.Context, code ErrorCode, format string, a ...interface{}) {
+	respondError(ctx, newAPIError(code, format, a...))
+}
+
+// 返回请求参数校验失败的错误
+func respondBindError(ctx *gin.Context, err error) {
+	respondErrorCode(ctx, ErrInvalidArgument, "%s", err)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// 链码的结构化错误按错误码映射为 HTTP 状态码，出错的字段原样返回
+func TestChaincodeErrorStatus(t *testing.T) {
+	tests := []struct {
+		message string
+		code    ErrorCode
+		field   string
+		status  int
+	}{
+		{`{"code":"COLLATERAL_NOT_FOUND","field":"collateralId","message":"Collateral not found: c1"}`, ErrCollateralNotFound, "collateralId", http.StatusNotFound},
+		{`{"code":"COLLATERAL_EXISTS","field":"collateralId","message":"Collateral already exist: c1"}`, ErrCollateralExists, "collateralId", http.StatusConflict},
+		{`{"code":"PROJECT_EXISTS","field":"projectId","message":"Project already exist: p1"}`, ErrProjectExists, "projectId", http.StatusConflict},
+		{`{"code":"INVALID_ARGUMENT","message":"Incorrect number of arguments. Expecting 2"}`, ErrInvalidArgument, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		err := status.New(status.EndorserServerStatus, 500, "chaincode error (status: 500, message: "+tt.message+")", nil)
+		apiErr := toAPIError(err)
+		if apiErr.Code != tt.code || apiErr.Field != tt.field {
+			t.Errorf("toAPIError(%s) = %s field %q, want %s field %q", tt.message, apiErr.Code, apiErr.Field, tt.code, tt.field)
+		}
+		if got := apiErr.Code.HTTPStatus(); got != tt.status {
+			t.Errorf("%s.HTTPStatus() = %d, want %d", apiErr.Code, got, tt.status)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 订阅的链码事件名称，与链码 events.go 中的定义保持一致（见 events_test.go）
+var chaincodeEventNames = []string{
+	"CustomerCreated",       //新建客户
+	"CustomerUpdated",       //修改客户信息
+	"CustomerArchived",      //归档客户
+	"CollateralAdded",       //添加押品
+	"CollateralReleased",    //押品解押
+	"CollateralTransferred", //押品转让
+	"ProjectAdded",          //添加项目（审批未通过）
+	"ProjectApproved",       //添加审批通过的项目
+}
+
+// AssetEvent 链码事件内容
+type AssetEvent struct {
+	Key     string                 `json:"key"`     //变化记录的键
+	TxID    string                 `json:"txId"`    //交易id
+	Changed map[string]interface{} `json:"changed"` //变化的字段及新值
+}
+
+// 订阅链码 chaincodeID 的全部资产事件
+func subscribeAssetEvents(listener *Listener, chaincodeID string, h ChaincodeEventHandler) {
+	for _, name := range chaincodeEventNames {
+		listener.AddChaincodeEventHandler(chaincodeID, name, h)
+	}
+}
+
+// 记录链码事件，作为监听服务的链码事件处理器
+func logChaincodeEvent(evt *ChaincodeEvent) error {
+	var assetEvent AssetEvent
+	if err := json.Unmarshal(evt.Payload, &assetEvent); err != nil {
+		// 内容无法解析的事件重试也不会成功，只记录不返回错误
+		fmt.Printf("invalid payload of chaincode event %s in tx %s: %s\n", evt.EventName, evt.TxID, err)
+		return nil
+	}
+	fmt.Printf("received chaincode event %s of tx %s in block %d: key=%q changed=%v\n",
+		evt.EventName, evt.TxID, evt.BlockNumber, assetEvent.Key, assetEvent.Changed)
+	return nil
+}
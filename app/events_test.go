@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// 网关订阅的事件名称与链码 events.go 中定义的一致
+func TestChaincodeEventNamesMatchChaincode(t *testing.T) {
+	src, err := ioutil.ReadFile("../chaincode/assetsManagement/go/events.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range regexp.MustCompile(`(?m)^\s*Event\w+\s*=\s*"(\w+)"`).FindAllStringSubmatch(string(src), -1) {
+		names = append(names, m[1])
+	}
+	if !reflect.DeepEqual(names, chaincodeEventNames) {
+		t.Errorf("chaincode events = %v, gateway subscribes to %v", names, chaincodeEventNames)
+	}
+}
+
+// 只把订阅的链码和事件名称分发给处理器，无效交易中的事件不分发
+func TestListenerDispatchesSubscribedEvents(t *testing.T) {
+	listener := NewListener("", nil)
+	var received []string
+	subscribeAssetEvents(listener, "assetscc", ChaincodeEventHandlerFunc(func(evt *ChaincodeEvent) error {
+		received = append(received, evt.TxID)
+		return nil
+	}))
+
+	block := &Block{Transactions: []*Transaction{
+		{Valid: true, Events: []*ChaincodeEvent{{TxID: "tx1", ChaincodeID: "assetscc", EventName: "CustomerCreated"}}},
+		{Valid: true, Events: []*ChaincodeEvent{{TxID: "tx2", ChaincodeID: "assetscc", EventName: "eventname"}}},
+		{Valid: true, Events: []*ChaincodeEvent{{TxID: "tx3", ChaincodeID: "marbles", EventName: "CustomerCreated"}}},
+		{Valid: false, Events: []*ChaincodeEvent{{TxID: "tx4", ChaincodeID: "assetscc", EventName: "CollateralAdded"}}},
+		{Valid: true, Events: []*ChaincodeEvent{{TxID: "tx5", ChaincodeID: "assetscc", EventName: "CollateralTransferred"}}},
+	}}
+	if err := listener.dispatch(block); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tx1", "tx5"}; !reflect.DeepEqual(received, want) {
+		t.Errorf("received %v, want %v", received, want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// 区块浏览器接口
+// 通过账本客户端读取区块，解析为 JSON（区块头、交易头、提交者组织、链码调用、读写集、验证结果），
+// 解析方式与监听服务相同，见 block.go
+
+// 按区间查询时一次最多返回的区块数
+const maxBlockRange = 50
+
+// 账本客户端，来自客户端池
+func ledgerClient(t *Target) (*ledger.Client, error) {
+	return clients.Ledger(t)
+}
+
+// 返回账本查询的错误，区块或交易不存在时返回 404，连接失败时重建客户端
+// qscc 没有结构化的错误码，只能按错误信息判断
+func respondLedgerError(ctx *gin.Context, err error) {
+	if isConnectionError(err) {
+		clients.Reset(target(ctx))
+	}
+	if msg := err.Error(); strings.Contains(msg, "not found") || strings.Contains(msg, "no such") {
+		respondErrorCode(ctx, ErrNotFound, "%s", msg)
+		return
+	}
+	respondError(ctx, err)
+}
+
+// 按区块号查询区块
+func queryBlockByNumber(ctx *gin.Context) {
+	number, err := strconv.ParseUint(ctx.Param("number"), 10, 64)
+	if err != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "block number must be a non-negative integer")
+		return
+	}
+	t := target(ctx)
+	cli, err := ledgerClient(t)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	raw, err := cli.QueryBlock(number, ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+	block, err := decodeBlock(raw)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondOK(ctx, block)
+}
+
+// 按区块哈希查询区块，路由为 /blocks/hash/:hash
+// gin 的路由不允许 /blocks/:number 与 /blocks/hash/:hash 并存，因此注册为 /blocks/:number/:hash，第一段必须为 hash
+func queryBlockByHash(ctx *gin.Context) {
+	if ctx.Param("number") != "hash" {
+		respondErrorCode(ctx, ErrNotFound, "404 page not found")
+		return
+	}
+	hash, err := hex.DecodeString(ctx.Param("hash"))
+	if err != nil || len(hash) == 0 {
+		respondErrorCode(ctx, ErrInvalidArgument, "block hash must be a hex string")
+		return
+	}
+	t := target(ctx)
+	cli, err := ledgerClient(t)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	raw, err := cli.QueryBlockByHash(hash, ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+	block, err := decodeBlock(raw)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondOK(ctx, block)
+}
+
+// 按区间查询区块，from 默认为 0，to 默认为最新区块（含两端），一次最多返回 maxBlockRange 个区块
+func queryBlocks(ctx *gin.Context) {
+	t := target(ctx)
+	cli, err := ledgerClient(t)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	info, err := cli.QueryInfo(ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+	if info.BCI.Height == 0 {
+		respondOK(ctx, []*Block{})
+		return
+	}
+
+	from, to := uint64(0), info.BCI.Height-1
+	if v := ctx.Query("from"); v != "" {
+		if from, err = strconv.ParseUint(v, 10, 64); err != nil {
+			respondErrorCode(ctx, ErrInvalidArgument, "from must be a non-negative integer")
+			return
+		}
+	}
+	if v := ctx.Query("to"); v != "" {
+		if to, err = strconv.ParseUint(v, 10, 64); err != nil {
+			respondErrorCode(ctx, ErrInvalidArgument, "to must be a non-negative integer")
+			return
+		}
+		if to > info.BCI.Height-1 {
+			to = info.BCI.Height - 1
+		}
+	}
+	if from > to {
+		respondErrorCode(ctx, ErrInvalidArgument, "from must not be greater than to")
+		return
+	}
+	if to-from >= maxBlockRange {
+		respondErrorCode(ctx, ErrInvalidArgument, "at most %d blocks per request", maxBlockRange)
+		return
+	}
+
+	blocks := []*Block{}
+	for number := from; number <= to; number++ {
+		raw, err := cli.QueryBlock(number, ledger.WithTargetEndpoints(t.ledgerPeer()))
+		if err != nil {
+			respondLedgerError(ctx, err)
+			return
+		}
+		block, err := decodeBlock(raw)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		blocks = append(blocks, block)
+	}
+	respondOK(ctx, blocks)
+}
+
+// 按交易id查询交易，同时返回所在的区块号
+func queryTransaction(ctx *gin.Context) {
+	txID := ctx.Param("txid")
+	t := target(ctx)
+	cli, err := ledgerClient(t)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	raw, err := cli.QueryBlockByTxID(fab.TransactionID(txID), ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+	block, err := decodeBlock(raw)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	for _, tx := range block.Transactions {
+		if tx.TxID == txID {
+			respondOK(ctx, gin.H{"blockNumber": block.Number, "transaction": tx})
+			return
+		}
+	}
+	respondErrorCode(ctx, ErrNotFound, "transaction not found")
+}
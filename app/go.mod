@@ -4,6 +4,11 @@ go 1.13
 
 require (
 	github.com/gin-gonic/gin v1.6.1
+	github.com/golang/protobuf v1.3.3
 	github.com/hyperledger/fabric-protos-go v0.0.0-20190821180310-6b6ac9042dfd
 	github.com/hyperledger/fabric-sdk-go v1.0.0-beta1
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/pkg/errors v0.8.1
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	gopkg.in/yaml.v2 v2.2.8
 )
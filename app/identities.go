@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+)
+
+// 用户身份管理
+// 在组织的 Fabric CA 注册（register）用户并登记（enroll）证书，登记后的身份记录在本地钱包中（见 wallet.go），
+// 请求头 X-Identity 指定该身份时以用户自己的证书签名交易。
+// 注册和吊销使用 config.yaml 中 CA 的 registrar；注册时指定的 role 写入证书属性，链码按它控制权限。
+
+// IdentityRegistration 注册用户
+type IdentityRegistration struct {
+	Name           string `form:"name" json:"name" binding:"required"`  //用户名
+	Org            string `form:"org" json:"org"`                       //所属组织，默认为默认身份的组织
+	Role           string `form:"role" json:"role"`                     //证书中的 role 属性，如 loanOfficer、auditor
+	Affiliation    string `form:"affiliation" json:"affiliation"`       //所属部门，如 org1.department1，默认与 registrar 相同
+	Secret         string `form:"secret" json:"secret"`                 //登记密码，未指定时由 CA 生成
+	MaxEnrollments int    `form:"maxEnrollments" json:"maxEnrollments"` //登记密码可以使用的次数，默认使用 CA 的配置
+}
+
+// IdentityEnrollment 登记证书
+type IdentityEnrollment struct {
+	Name   string `form:"name" json:"name" binding:"required"`     //用户名
+	Org    string `form:"org" json:"org"`                          //所属组织，默认为注册时的组织
+	Secret string `form:"secret" json:"secret" binding:"required"` //登记密码
+}
+
+// IdentityRevocation 吊销身份
+type IdentityRevocation struct {
+	Name   string `form:"name" json:"name" binding:"required"` //用户名
+	Reason string `form:"reason" json:"reason"`                //吊销原因，如 keycompromise、superseded
+}
+
+// 钱包中的全部身份
+func listIdentities(ctx *gin.Context) {
+	respondOK(ctx, wallet.List())
+}
+
+// 查询钱包中的身份
+func queryIdentity(ctx *gin.Context) {
+	identity, ok := wallet.Get(ctx.Param("name"))
+	if !ok {
+		respondErrorCode(ctx, ErrNotFound, "identity %q is not in the wallet", ctx.Param("name"))
+		return
+	}
+	respondOK(ctx, identity)
+}
+
+// 在 CA 注册用户，返回登记密码
+func registerIdentity(ctx *gin.Context) {
+	req := new(IdentityRegistration)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	if cfg.identity(req.Name) != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "identity %q is configured in the gateway", req.Name)
+		return
+	}
+	org := req.Org
+	if org == "" {
+		org = defaultOrg()
+	}
+	cli, ok := caClient(ctx, org)
+	if !ok {
+		return
+	}
+
+	var attributes []msp.Attribute
+	if req.Role != "" {
+		attributes = append(attributes, msp.Attribute{Name: "role", Value: req.Role, ECert: true})
+	}
+	secret, err := cli.Register(&msp.RegistrationRequest{
+		Name:           req.Name,
+		Type:           "client",
+		MaxEnrollments: req.MaxEnrollments,
+		Affiliation:    req.Affiliation,
+		Attributes:     attributes,
+		Secret:         req.Secret,
+	})
+	if err != nil {
+		respondErrorCode(ctx, ErrCA, "register %s error, %s", req.Name, err)
+		return
+	}
+
+	identity := WalletIdentity{Name: req.Name, Org: org, Role: req.Role, Status: IdentityRegistered}
+	if err := wallet.Put(identity); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondOK(ctx, gin.H{"name": req.Name, "org": org, "secret": secret})
+}
+
+// 用登记密码登记证书，证书和私钥保存在 SDK 的 credentialStore 中
+func enrollIdentity(ctx *gin.Context) {
+	req := new(IdentityEnrollment)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	if cfg.identity(req.Name) != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "identity %q is configured in the gateway", req.Name)
+		return
+	}
+	// 在其他地方注册的用户也可以登记，此时钱包中还没有记录
+	identity, ok := wallet.Get(req.Name)
+	if !ok {
+		identity = WalletIdentity{Name: req.Name, Org: defaultOrg()}
+	}
+	if req.Org != "" {
+		identity.Org = req.Org
+	}
+	cli, ok := caClient(ctx, identity.Org)
+	if !ok {
+		return
+	}
+
+	if err := cli.Enroll(req.Name, msp.WithSecret(req.Secret)); err != nil {
+		respondErrorCode(ctx, ErrCA, "enroll %s error, %s", req.Name, err)
+		return
+	}
+	saveEnrollment(ctx, cli, identity)
+}
+
+// 重新登记证书，用于证书即将到期或需要更新证书属性
+func reenrollIdentity(ctx *gin.Context) {
+	identity, ok := enrolledIdentity(ctx)
+	if !ok {
+		return
+	}
+	cli, ok := caClient(ctx, identity.Org)
+	if !ok {
+		return
+	}
+
+	if err := cli.Reenroll(identity.Name); err != nil {
+		respondErrorCode(ctx, ErrCA, "reenroll %s error, %s", identity.Name, err)
+		return
+	}
+	saveEnrollment(ctx, cli, identity)
+}
+
+// 吊销身份的全部证书，吊销后不能再用该身份调用链码
+func revokeIdentity(ctx *gin.Context) {
+	req := new(IdentityRevocation)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	identity, ok := wallet.Get(req.Name)
+	if !ok {
+		respondErrorCode(ctx, ErrNotFound, "identity %q is not in the wallet", req.Name)
+		return
+	}
+	cli, ok := caClient(ctx, identity.Org)
+	if !ok {
+		return
+	}
+
+	if _, err := cli.Revoke(&msp.RevocationRequest{Name: req.Name, Reason: req.Reason}); err != nil {
+		respondErrorCode(ctx, ErrCA, "revoke %s error, %s", req.Name, err)
+		return
+	}
+	identity.Status = IdentityRevoked
+	if err := wallet.Put(identity); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	clients.ResetIdentity(identity.Org, identity.Name)
+	identity, _ = wallet.Get(identity.Name)
+	respondOK(ctx, identity)
+}
+
+// 钱包中已登记的身份，不存在或未登记时返回错误
+func enrolledIdentity(ctx *gin.Context) (WalletIdentity, bool) {
+	name := ctx.Param("name")
+	identity, ok := wallet.Get(name)
+	switch {
+	case !ok:
+		respondErrorCode(ctx, ErrNotFound, "identity %q is not in the wallet", name)
+		return identity, false
+	case identity.Status != IdentityEnrolled:
+		respondErrorCode(ctx, ErrInvalidArgument, "identity %q is %s", name, identity.Status)
+		return identity, false
+	}
+	return identity, true
+}
+
+// 组织的 CA 客户端，组织不存在或没有配置 CA 时返回 400
+func caClient(ctx *gin.Context, org string) (*msp.Client, bool) {
+	cli, err := clients.MSP(org)
+	if err != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "org %q has no certificate authority, %s", org, err)
+		return nil, false
+	}
+	return cli, true
+}
+
+// 登记成功后记录证书信息，并丢弃该身份用旧证书创建的客户端
+func saveEnrollment(ctx *gin.Context, cli *msp.Client, identity WalletIdentity) {
+	signingIdentity, err := cli.GetSigningIdentity(identity.Name)
+	if err != nil {
+		respondError(ctx, fmt.Errorf("load enrolled identity %s error, %s", identity.Name, err))
+		return
+	}
+	if block, _ := pem.Decode(signingIdentity.EnrollmentCertificate()); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			identity.Serial = fmt.Sprintf("%x", cert.SerialNumber)
+			identity.Expires = &cert.NotAfter
+		}
+	}
+	identity.Status = IdentityEnrolled
+	now := time.Now()
+	identity.EnrolledAt = &now
+	if err := wallet.Put(identity); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	clients.ResetIdentity(identity.Org, identity.Name)
+	identity, _ = wallet.Get(identity.Name)
+	respondOK(ctx, identity)
+}
+
+// 默认身份所属的组织
+func defaultOrg() string {
+	return cfg.identity(cfg.Default.Identity).Org
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+)
+
+// BlockHandler 区块处理器
+// 区块按区块号顺序逐个交给处理器，返回错误时稍后重试同一个区块，不会跳过
+type BlockHandler interface {
+	HandleBlock(block *Block) error
+}
+
+// BlockHandlerFunc 把函数转换为 BlockHandler
+type BlockHandlerFunc func(block *Block) error
+
+// HandleBlock 实现 BlockHandler
+func (f BlockHandlerFunc) HandleBlock(block *Block) error {
+	return f(block)
+}
+
+// ChaincodeEventHandler 链码事件处理器，只收到有效交易中的链码事件
+type ChaincodeEventHandler interface {
+	HandleChaincodeEvent(evt *ChaincodeEvent) error
+}
+
+// ChaincodeEventHandlerFunc 把函数转换为 ChaincodeEventHandler
+type ChaincodeEventHandlerFunc func(evt *ChaincodeEvent) error
+
+// HandleChaincodeEvent 实现 ChaincodeEventHandler
+func (f ChaincodeEventHandlerFunc) HandleChaincodeEvent(evt *ChaincodeEvent) error {
+	return f(evt)
+}
+
+// 处理器出错后重试的间隔
+const listenerRetryInterval = 5 * time.Second
+
+// Listener 区块事件监听服务
+// 通过 deliver 服务从检查点之后的区块开始订阅完整区块，每个区块的全部处理器执行成功后
+// 才把区块号写入检查点文件，重启后从检查点的下一个区块继续，不会遗漏区块，已处理的区块也不会再次处理。
+// 只有在处理器执行完成、检查点尚未写入时程序退出，重启后才会再次收到该区块，处理器应当能够重复处理同一区块
+type Listener struct {
+	checkpointFile string
+	target         *Target // 监听的通道和身份
+
+	blockHandlers []BlockHandler
+	eventHandlers map[chaincodeEvent][]ChaincodeEventHandler
+
+	client *event.Client
+	reg    fab.Registration
+
+	mu          sync.Mutex
+	checkpoint  uint64 //最后处理完成的区块号
+	initialized bool   //是否已经处理过区块（检查点文件是否存在）
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewListener 创建监听服务，checkpointFile 为检查点文件的路径，target 为监听的通道和身份
+func NewListener(checkpointFile string, target *Target) *Listener {
+	return &Listener{
+		checkpointFile: checkpointFile,
+		target:         target,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// AddBlockHandler 添加区块处理器，需要在 Start 之前调用
+func (l *Listener) AddBlockHandler(h BlockHandler) {
+	l.blockHandlers = append(l.blockHandlers, h)
+}
+
+// 处理器订阅的链码和事件名称
+type chaincodeEvent struct {
+	chaincodeID string
+	eventName   string
+}
+
+// AddChaincodeEventHandler 订阅链码 chaincodeID 名为 eventName 的事件，需要在 Start 之前调用
+func (l *Listener) AddChaincodeEventHandler(chaincodeID, eventName string, h ChaincodeEventHandler) {
+	if l.eventHandlers == nil {
+		l.eventHandlers = make(map[chaincodeEvent][]ChaincodeEventHandler)
+	}
+	key := chaincodeEvent{chaincodeID: chaincodeID, eventName: eventName}
+	l.eventHandlers[key] = append(l.eventHandlers[key], h)
+}
+
+// Checkpoint 返回最后处理完成的区块号，还没有处理过区块时 ok 为 false
+func (l *Listener) Checkpoint() (number uint64, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkpoint, l.initialized
+}
+
+// Start 读取检查点并开始监听
+func (l *Listener) Start() error {
+	if err := l.loadCheckpoint(); err != nil {
+		return err
+	}
+
+	// 没有检查点时从创世区块开始
+	from, ok := l.Checkpoint()
+	if ok {
+		from++
+	}
+
+	cli, err := event.New(l.target.channelContext(), event.WithBlockEvents(), event.WithSeekType(seek.FromBlock), event.WithBlockNum(from))
+	if err != nil {
+		return fmt.Errorf("create event client error, %s", err)
+	}
+	reg, blkevent, err := cli.RegisterBlockEvent()
+	if err != nil {
+		return fmt.Errorf("register block event error, %s", err)
+	}
+	l.client, l.reg = cli, reg
+
+	fmt.Printf("listener started from block %d\n", from)
+	go l.run(blkevent)
+	return nil
+}
+
+// Stop 停止监听，等待正在处理的区块完成
+func (l *Listener) Stop() {
+	if l.client == nil {
+		return
+	}
+	close(l.stop)
+	l.client.Unregister(l.reg)
+	<-l.done
+}
+
+func (l *Listener) run(blkevent <-chan *fab.BlockEvent) {
+	defer close(l.done)
+	for {
+		select {
+		case evt, ok := <-blkevent:
+			if !ok {
+				fmt.Println("listener: block event channel closed")
+				return
+			}
+			if !l.process(evt) {
+				return
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// 处理一个区块，直到成功或收到停止信号，返回 false 表示需要停止
+func (l *Listener) process(evt *fab.BlockEvent) bool {
+	block, err := decodeBlock(evt.Block)
+	if err != nil {
+		// 无法解析的区块重试也不会成功，停止监听等待人工处理，检查点保持不变
+		fmt.Printf("listener: %s, stopped\n", err)
+		return false
+	}
+
+	// 重新连接时可能收到已经处理过的区块
+	if last, ok := l.Checkpoint(); ok && block.Number <= last {
+		return true
+	}
+
+	for {
+		err := l.dispatch(block)
+		if err == nil {
+			break
+		}
+		fmt.Printf("listener: handle block %d error, %s, retry in %s\n", block.Number, err, listenerRetryInterval)
+		select {
+		case <-time.After(listenerRetryInterval):
+		case <-l.stop:
+			return false
+		}
+	}
+
+	if err := l.saveCheckpoint(block.Number); err != nil {
+		fmt.Printf("listener: save checkpoint of block %d error, %s\n", block.Number, err)
+	}
+	return true
+}
+
+// 把区块和其中有效交易的链码事件交给各处理器
+func (l *Listener) dispatch(block *Block) error {
+	for _, h := range l.blockHandlers {
+		if err := h.HandleBlock(block); err != nil {
+			return err
+		}
+	}
+	for _, tx := range block.Transactions {
+		if !tx.Valid {
+			continue
+		}
+		for _, evt := range tx.Events {
+			for _, h := range l.eventHandlers[chaincodeEvent{chaincodeID: evt.ChaincodeID, eventName: evt.EventName}] {
+				if err := h.HandleChaincodeEvent(evt); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 读取检查点文件，文件不存在时表示还没有处理过区块
+func (l *Listener) loadCheckpoint() error {
+	data, err := ioutil.ReadFile(l.checkpointFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read checkpoint error, %s", err)
+	}
+	number, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint file %s, %s", l.checkpointFile, err)
+	}
+
+	l.mu.Lock()
+	l.checkpoint, l.initialized = number, true
+	l.mu.Unlock()
+	return nil
+}
+
+// 写入检查点，先写临时文件再重命名，避免写到一半时程序退出损坏检查点
+func (l *Listener) saveCheckpoint(number uint64) error {
+	l.mu.Lock()
+	l.checkpoint, l.initialized = number, true
+	l.mu.Unlock()
+
+	if dir := filepath.Dir(l.checkpointFile); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := l.checkpointFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(number, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.checkpointFile)
+}
@@ -1,445 +1,656 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
-	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/filter"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
-	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 )
 
 func main() {
+	rebuildStore := flag.Bool("rebuild-store", false, "清空链下查询库，从创世区块重新同步后退出")
+	flag.Parse()
+
+	// 读取并校验配置，配置有误时直接退出
+	var err error
+	if cfg, err = loadConfig(); err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	initSDK()
+	if err := cfg.ValidateNetwork(sdk); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if wallet, err = OpenWallet(cfg.Wallet); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !cfg.Auth.Enabled {
+		fmt.Println("warning: authentication is disabled, anyone who can reach", cfg.Listen, "can call every endpoint")
+	} else if auditLog, err = OpenAuditLog(cfg.Auth.AuditLog); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *rebuildStore {
+		err := rebuild()
+		clients.Close()
+		if err != nil {
+			fmt.Println("rebuild store error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 打开链下查询库并补齐缺少的区块，失败时报表接口不可用，不影响其他接口
+	// 查询库和监听服务只处理默认通道上的默认链码
+	if store, err = OpenStore(cfg.Store, cfg.DefaultTarget()); err != nil {
+		fmt.Println("open store error:", err)
+	} else if err := store.Sync(); err != nil {
+		fmt.Println("sync store error:", err)
+	}
+
+	// 启动区块事件监听服务，失败时不影响接口服务
+	listener := NewListener(cfg.Checkpoint, cfg.DefaultTarget())
+	if store != nil {
+		listener.AddBlockHandler(store)
+	}
+	subscribeAssetEvents(listener, cfg.Default.Chaincode, ChaincodeEventHandlerFunc(logChaincodeEvent))
+	if err := listener.Start(); err != nil {
+		fmt.Println("start listener error:", err)
+	}
 
 	engine := gin.Default()
-	{
-		engine.GET("/getChainInfo", queryBlockchainInfo)              //查询区块链信息
-		engine.POST("/addCustomerInfo", addCustomer)                  //添加客户信息
-		engine.POST("/addCollateralInfo", addCollateral)              //添加押品
-		engine.POST("/addProjectInfo", addProject)                    //添加项目
-		engine.GET("/getCustomerInfo", queryCustomerInfo)             //查询客户信息
-		engine.GET("/getHistoryCustomerInfo", getHistoryCustomer)     //客户历史信息查询
-		engine.GET("/getHistoryCollateralInfo", getHistoryCollateral) //押品变更历史查询
-		engine.GET("/getHistoryProjectInfo", getHistoryProject)       //项目历史信息查询
-
-		// engine.GET("/blockchaininfo", queryBlockchainInfo)            //查询区块链信息
-		// engine.POST("/customer", addCustomer)                         //添加客户信息
-		// engine.POST("/collateral", addCollateral)                     //添加押品
-		// engine.POST("/project", addProject)                           //添加项目
-		// engine.GET("/customer", queryCustomerInfo)              //查询客户信息
-		// engine.GET("/history/customer", getHistoryCustomer)     //客户历史信息查询
-		// engine.GET("/history/collateral", getHistoryCollateral) //押品变更历史查询
-		// engine.GET("/history/project", getHistoryProject)       //项目历史信息查询
-	}
-
-	engine.Run() // listen and serve on 0.0.0.0:8080，默认端口 8080
-	// engine.Run(":8086") 通过此方式修改监听的端口，此时监听 8086 端口
+	registerRoutes(engine)
+
+	// 监听地址见配置文件，默认 :8080
+	srv := &http.Server{Addr: cfg.Listen, Handler: engine}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("listen error:", err)
+			os.Exit(1)
+		}
+	}()
+
+	// 收到 SIGINT/SIGTERM 后停止接收新请求，等待处理中的请求完成，再依次停止监听服务、关闭查询库和 SDK 连接
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	fmt.Println("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("shutdown server error:", err)
+	}
+	listener.Stop()
+	if store != nil {
+		store.Close()
+	}
+	clients.Close()
+	if auditLog != nil {
+		auditLog.Close()
+	}
 }
 
+// 关闭时等待处理中请求的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func queryBlockchainInfo(ctx *gin.Context) {
-	resp := queryBlockchain()
+	resp, err := queryBlockchain(target(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
 
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // Customer 客户关键字
+// 请求体可以是表单或 JSON，金额在 JSON 中可以是数字或字符串，日期格式为 ISO-8601（2006-01-02），与链码保持一致
 type Customer struct {
-	Name         string `form:"name" binding:"required"`         //客户姓名
-	ID           string `form:"id" binding:"required"`           //客户编号
-	Code         string `form:"code" binding:"required"`         //统一社会信用代码
-	Type         string `form:"type" binding:"required"`         //类型
-	Money        string `form:"money" binding:"required"`        //注册资本
-	Person       string `form:"person" binding:"required"`       //法人代表
-	Date         string `form:"date" binding:"required"`         //成立日期
-	BusinessDate string `form:"businessDate" binding:"required"` //营业期限
-	ApprovalDate string `form:"approvalDate" binding:"required"` //核准日期
-	Trade        string `form:"trade" binding:"required"`        //所属行业
-}
-
-// 添加客户信息
-func addCustomer(ctx *gin.Context) {
-	// 参数处理
-	req := new(Customer)
-	if err := ctx.ShouldBind(req); err != nil {
-		ctx.AbortWithError(400, err)
-		return
+	Name         string      `form:"name" json:"name" binding:"required"`                                     //客户姓名
+	ID           string      `form:"id" json:"id" binding:"required"`                                         //客户编号
+	Code         string      `form:"code" json:"code" binding:"required,len=18"`                              //统一社会信用代码
+	Type         string      `form:"type" json:"type" binding:"required"`                                     //类型
+	Money        json.Number `form:"money" json:"money" binding:"required,numeric"`                           //注册资本
+	Currency     string      `form:"currency" json:"currency" binding:"omitempty,len=3"`                      //币种，默认 CNY
+	Person       string      `form:"person" json:"person" binding:"required"`                                 //法人代表
+	Date         string      `form:"date" json:"date" binding:"required,datetime=2006-01-02"`                 //成立日期
+	BusinessDate string      `form:"businessDate" json:"businessDate" binding:"required,datetime=2006-01-02"` //营业期限，不早于成立日期，由链码校验
+	ApprovalDate string      `form:"approvalDate" json:"approvalDate" binding:"required,datetime=2006-01-02"` //核准日期
+	Trade        string      `form:"trade" json:"trade" binding:"required"`                                   //所属行业
+}
+
+// 金额转换为链码参数，格式为 "金额[ 币种]"
+func moneyArg(amount, currency string) string {
+	if currency == "" {
+		return amount
 	}
+	return amount + " " + currency
+}
 
-	// 区块链交互
-	resp, err := channelExecute("addCustomerInfo", [][]byte{
+// 客户信息转换为链码参数
+func customerArgs(req *Customer) [][]byte {
+	return [][]byte{
 		[]byte(req.Name),
 		[]byte(req.ID),
 		[]byte(req.Code),
 		[]byte(req.Type),
-		[]byte(req.Money),
+		[]byte(moneyArg(req.Money.String(), req.Currency)),
 		[]byte(req.Person),
 		[]byte(req.Date),
 		[]byte(req.BusinessDate),
 		[]byte(req.ApprovalDate),
 		[]byte(req.Trade),
-	})
+	}
+}
 
-	// 因为 postman 对于 非200-300 直接的错误，会直接返回错误编号，而不显示错误内容
-	// 所以此处通过 200 直接返回，并显示错误内容
-	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+// 添加客户信息，客户已存在时链码返回错误，不会覆盖
+func addCustomer(ctx *gin.Context) {
+	// 参数处理
+	req := new(Customer)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	// 区块链交互
+	submitTx(ctx, "createCustomer", customerArgs(req))
+}
+
+// 修改客户信息，客户不存在时链码返回错误
+func updateCustomer(ctx *gin.Context) {
+	req := new(Customer)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	submitTx(ctx, "updateCustomer", customerArgs(req))
+}
+
+// 归档客户
+func archiveCustomer(ctx *gin.Context) {
+	id := param(ctx, "id")
+
+	submitTx(ctx, "archiveCustomer", [][]byte{
+		[]byte(id),
+	})
+}
+
+// 认领没有所属组织的早期客户，认领后归属于调用身份的组织
+func claimCustomer(ctx *gin.Context) {
+	submitTx(ctx, "claimCustomer", [][]byte{
+		[]byte(param(ctx, "id")),
+	})
 }
 
 // 查询客户信息
 func queryCustomerInfo(ctx *gin.Context) {
-	// 若参数在 path 中，用 Param() 方法来提取参数
-	// user := ctx.Param("name")
-	user := ctx.Query("name")
+	// 参数在 path 中（/api/v1）或 query 中（旧接口）
+	id := param(ctx, "id")
 
-	resp, err := channelQuery("getCustomerInfo", [][]byte{
-		[]byte(user),
+	resp, err := channelQuery(target(ctx), "getCustomerInfo", [][]byte{
+		[]byte(id),
 	})
 
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		respondError(ctx, err)
+		return
+	}
+
+	// 返回 Payload（对于接收者有用的数据）
+	respondPayload(ctx, resp.Payload)
+}
+
+// 查询客户在指定时间点的信息（客户信息、押品、项目）
+// timestamp 为 RFC3339 时间（如 2020-06-30T18:00:00+08:00）或日期（如 2020-06-30，表示当天结束时）
+func queryCustomerAsOf(ctx *gin.Context) {
+	resp, err := channelQuery(target(ctx), "getCustomerAsOf", [][]byte{
+		[]byte(param(ctx, "id")),
+		[]byte(ctx.Query("timestamp")),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondPayload(ctx, resp.Payload)
+}
+
+// 按客户编号查询客户
+func queryCustomerByID(ctx *gin.Context) {
+	resp, err := channelQuery(target(ctx), "getCustomerById", [][]byte{
+		[]byte(param(ctx, "id")),
+	})
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	//ctx.JSON(http.StatusOK, resp)
-	// 返回 Payload（对于接收者有用的数据） 方便查看
-	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+	respondPayload(ctx, resp.Payload)
+}
+
+// 按统一社会信用代码查询客户
+func queryCustomerByCode(ctx *gin.Context) {
+	resp, err := channelQuery(target(ctx), "getCustomerByCode", [][]byte{
+		[]byte(ctx.Query("code")),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondPayload(ctx, resp.Payload)
+}
+
+// 按客户名称查询客户，客户名称可能重复，返回列表
+func queryCustomersByName(ctx *gin.Context) {
+	resp, err := channelQuery(target(ctx), "getCustomersByName", [][]byte{
+		[]byte(ctx.Query("name")),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondPayload(ctx, resp.Payload)
 }
 
 // 查询客户历史信息
 func getHistoryCustomer(ctx *gin.Context) {
-	// 若参数在 path 中，用 Param() 方法来提取参数
-	// user := ctx.Param("name")
-	user := ctx.Query("name")
+	// 参数在 path 中（/api/v1）或 query 中（旧接口）
+	id := param(ctx, "id")
 
-	resp, err := channelQuery("getHistoryCustomerInfo", [][]byte{
-		[]byte(user),
+	fcn, args := withPagination(ctx, "getHistoryCustomerInfo", [][]byte{
+		[]byte(id),
 	})
+	resp, err := channelQuery(target(ctx), fcn, withTimeRange(ctx, args))
 
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		respondError(ctx, err)
 		return
 	}
 
-	//ctx.JSON(http.StatusOK, resp)
-	// 返回 Payload（对于接收者有用的数据） 方便查看
-	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+	// 返回 Payload（对于接收者有用的数据）
+	respondPayload(ctx, resp.Payload)
 }
 
 // Collateral 押品信息
 type Collateral struct {
-	Name           string `form:"name" binding:"required"`           //客户姓名
-	CollateralID   string `form:"collateralId" binding:"required"`   //押品编号
-	CollateralName string `form:"collateralName" binding:"required"` //押品名称
+	ID             string `form:"id" json:"id" binding:"required"`                         //客户编号
+	CollateralID   string `form:"collateralId" json:"collateralId" binding:"required"`     //押品编号
+	CollateralName string `form:"collateralName" json:"collateralName" binding:"required"` //押品名称
 }
 
 // 资产登记
 func addCollateral(ctx *gin.Context) {
 	req := new(Collateral)
 	// 参数在 form 表单中，用 ShouldBind() 方法来提取参数
-	if err := ctx.ShouldBind(req); err != nil {
-		ctx.AbortWithError(400, err)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	resp, err := channelExecute("addCollateralInfo", [][]byte{
-		[]byte(req.Name),
+	submitTx(ctx, "addCollateral", [][]byte{
+		[]byte(req.ID),
 		[]byte(req.CollateralID),
 		[]byte(req.CollateralName),
 	})
-	fmt.Println(resp)
+}
 
-	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+// CollateralRelease 押品解押
+type CollateralRelease struct {
+	ID           string `form:"id" json:"id" binding:"required"`                     //客户编号
+	CollateralID string `form:"collateralId" json:"collateralId" binding:"required"` //押品编号
+}
+
+// 押品解押
+func releaseCollateral(ctx *gin.Context) {
+	req := new(CollateralRelease)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	submitTx(ctx, "releaseCollateral", [][]byte{
+		[]byte(req.ID),
+		[]byte(req.CollateralID),
+	})
 }
 
-// 押品变更历史查询
-func getHistoryCollateral(ctx *gin.Context) {
-	// 若参数在 path 中，用 Param() 方法来提取参数
-	// user := ctx.Param("name")
-	user := ctx.Query("name")
-	resp, err := channelQuery("getHistoryCollateralInfo", [][]byte{
-		[]byte(user),
+// CollateralTransfer 押品转让
+type CollateralTransfer struct {
+	ID           string `form:"id" json:"id" binding:"required"`                     //客户编号
+	CollateralID string `form:"collateralId" json:"collateralId" binding:"required"` //押品编号
+	NewID        string `form:"newId" json:"newId" binding:"required"`               //目标客户编号
+}
+
+// 押品转让
+func transferCollateral(ctx *gin.Context) {
+	req := new(CollateralTransfer)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	submitTx(ctx, "transferCollateral", [][]byte{
+		[]byte(req.ID),
+		[]byte(req.CollateralID),
+		[]byte(req.NewID),
 	})
+}
+
+// 押品变更历史查询，指定 collateralId 时只返回该押品的历史
+func getHistoryCollateral(ctx *gin.Context) {
+	// 参数在 path 中（/api/v1）或 query 中（旧接口）
+	id := param(ctx, "id")
+	// collateralId 为空时链码返回客户名下全部押品的历史
+	args := [][]byte{
+		[]byte(id),
+		[]byte(param(ctx, "collateralId")),
+	}
 
+	fcn, args := withPagination(ctx, "getHistoryCollateralInfo", args)
+	resp, err := channelQuery(target(ctx), fcn, withTimeRange(ctx, args))
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		respondError(ctx, err)
 		return
 	}
 
-	//ctx.JSON(http.StatusOK, resp)
-	// 返回 Payload（对于接收者有用的数据） 方便查看
-	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+	// 返回 Payload（对于接收者有用的数据）
+	respondPayload(ctx, resp.Payload)
 }
 
 // Project 项目信息
 type Project struct {
-	Name               string `form:"name" binding:"required"`               //客户名称
-	ProjectName        string `form:"projectName" binding:"required"`        //项目名称
-	ProjectID          string `form:"projectId" binding:"required"`          //项目编号
-	ProjectType        string `form:"projectType" binding:"required"`        //业务类型
-	ProjectTrade       string `form:"projectTrade" binding:"required"`       //所属行业
-	ProjectDate        string `form:"projectDate" binding:"required"`        //批复下达日
-	ProjectApprove     string `form:"projectApprove" binding:"required"`     //审批是否通过
-	ProjectPart        string `form:"projectPart" binding:"required"`        //是否成立有限合伙人
-	ProjectInvest      string `form:"projectInvest" binding:"required"`      //是否有自有资金投资
-	ProjectMoney       string `form:"projectMoney" binding:"required"`       //持有债券金额
-	ProjectCompanyType string `form:"projectCompanyType" binding:"required"` //被投资企业类型
-}
-
-//
+	ID                 string      `form:"id" json:"id" binding:"required"`                                       //客户编号
+	ProjectName        string      `form:"projectName" json:"projectName" binding:"required"`                     //项目名称
+	ProjectID          string      `form:"projectId" json:"projectId" binding:"required"`                         //项目编号
+	ProjectType        string      `form:"projectType" json:"projectType" binding:"required"`                     //业务类型
+	ProjectTrade       string      `form:"projectTrade" json:"projectTrade" binding:"required"`                   //所属行业
+	ProjectDate        string      `form:"projectDate" json:"projectDate" binding:"required,datetime=2006-01-02"` //批复下达日
+	ProjectApprove     *bool       `form:"projectApprove" json:"projectApprove" binding:"required"`               //审批是否通过
+	ProjectPart        *bool       `form:"projectPart" json:"projectPart" binding:"required"`                     //是否成立有限合伙人
+	ProjectInvest      *bool       `form:"projectInvest" json:"projectInvest" binding:"required"`                 //是否有自有资金投资
+	ProjectMoney       json.Number `form:"projectMoney" json:"projectMoney" binding:"required,numeric"`           //持有债券金额
+	ProjectCurrency    string      `form:"projectCurrency" json:"projectCurrency" binding:"omitempty,len=3"`      //币种，默认 CNY
+	ProjectCompanyType string      `form:"projectCompanyType" json:"projectCompanyType" binding:"required"`       //被投资企业类型
+}
+
 func addProject(ctx *gin.Context) {
 	req := new(Project)
 	// 参数在 form 表单中，用 ShouldBind() 方法来提取参数
 
-	if err := ctx.ShouldBind(req); err != nil {
-		ctx.AbortWithError(400, err)
+	if err := bindRequest(ctx, req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	resp, err := channelExecute("addProjectInfo", [][]byte{
-		[]byte(req.Name),
+	submitTx(ctx, "addProjectInfo", [][]byte{
+		[]byte(req.ID),
 		[]byte(req.ProjectName),
 		[]byte(req.ProjectID),
 		[]byte(req.ProjectType),
 		[]byte(req.ProjectTrade),
 		[]byte(req.ProjectDate),
-		[]byte(req.ProjectApprove),
-		[]byte(req.ProjectPart),
-		[]byte(req.ProjectInvest),
-		[]byte(req.ProjectMoney),
+		[]byte(strconv.FormatBool(*req.ProjectApprove)),
+		[]byte(strconv.FormatBool(*req.ProjectPart)),
+		[]byte(strconv.FormatBool(*req.ProjectInvest)),
+		[]byte(moneyArg(req.ProjectMoney.String(), req.ProjectCurrency)),
 		[]byte(req.ProjectCompanyType),
 	})
+}
+
+// 项目变更历史查询，指定 projectId 时只返回该项目的历史
+func getHistoryProject(ctx *gin.Context) {
+	// 参数在 path 中（/api/v1）或 query 中（旧接口）
+	id := param(ctx, "id")
+	// projectId 为空时链码返回客户名下全部项目的历史
+	args := [][]byte{
+		[]byte(id),
+		[]byte(param(ctx, "projectId")),
+	}
 
+	fcn, args := withPagination(ctx, "getHistoryProjectInfo", args)
+	resp, err := channelQuery(target(ctx), fcn, withTimeRange(ctx, args))
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	respondPayload(ctx, resp.Payload)
 }
 
-// 项目变更历史查询
-func getHistoryProject(ctx *gin.Context) {
-	// 若参数在 path 中，用 Param() 方法来提取参数
-	// user := ctx.Param("name")
-	user := ctx.Query("name")
-	resp, err := channelQuery("getHistoryProjectInfo", [][]byte{
-		[]byte(user),
+// 查询客户名下所有项目
+func listProjects(ctx *gin.Context) {
+	id := param(ctx, "id")
+	resp, err := channelQuery(target(ctx), "listProjectsByCustomer", [][]byte{
+		[]byte(id),
 	})
 
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		respondError(ctx, err)
+		return
+	}
+
+	respondPayload(ctx, resp.Payload)
+}
+
+// 富查询，需要 CouchDB 作为状态数据库
+// type=project 时按所属行业 trade、审批结果 approved、批复下达日区间 from/to 查询项目；
+// type=customer 时查询注册资本高于 minCapital（可选币种 currency）的客户；
+// 指定 query 时按 CouchDB 语法自定义查询
+func search(ctx *gin.Context) {
+	var fcn string
+	var args [][]byte
+
+	if query := ctx.Query("query"); query != "" {
+		fcn = "queryAssets"
+		args = [][]byte{[]byte(query)}
+	} else {
+		switch ctx.Query("type") {
+		case "project":
+			fcn = "queryProjects"
+			args = [][]byte{
+				[]byte(ctx.Query("trade")),
+				[]byte(ctx.Query("approved")),
+				[]byte(ctx.Query("from")),
+				[]byte(ctx.Query("to")),
+			}
+		case "customer":
+			fcn = "queryCustomersByCapital"
+			args = [][]byte{
+				[]byte(ctx.Query("minCapital")),
+				[]byte(ctx.Query("currency")),
+			}
+		default:
+			respondErrorCode(ctx, ErrInvalidArgument, "type must be project or customer")
+			return
+		}
+	}
+
+	fcn, args = withPagination(ctx, fcn, args)
+	resp, err := channelQuery(target(ctx), fcn, args)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+	respondPayload(ctx, resp.Payload)
+}
+
+// 分页参数
+// 请求中指定 pageSize 时改为调用链码的 WithPagination 版本，并追加每页记录数和书签 bookmark，
+// 返回 {"records":[...], "fetchedRecordsCount":n, "bookmark":"..."}，取下一页时传入上一页返回的 bookmark
+func withPagination(ctx *gin.Context, fcn string, args [][]byte) (string, [][]byte) {
+	pageSize := ctx.Query("pageSize")
+	if pageSize == "" {
+		return fcn, args
+	}
+	return fcn + "WithPagination", append(args, []byte(pageSize), []byte(ctx.Query("bookmark")))
+}
+
+// 历史查询的起止时间
+// 请求中指定 from 或 to 时追加到参数末尾，取值为 RFC3339 时间（如 2020-01-02T15:04:05+08:00）或日期（如 2020-01-02）
+func withTimeRange(ctx *gin.Context, args [][]byte) [][]byte {
+	from, to := ctx.Query("from"), ctx.Query("to")
+	if from == "" && to == "" {
+		return args
+	}
+	return append(args, []byte(from), []byte(to))
 }
 
-// 代码中用到的名字都是 yaml文件中的 key 而不是 value
 var (
-	sdk           *fabsdk.FabricSDK
-	channelName   = "mychannel"
-	chaincodeName = "assetscc"
-	org           = "org1" // 对应了 configtx.yaml 文件的160行
-	user          = "Admin"
-	configPath    = "./config.yaml"
+	sdk     *fabsdk.FabricSDK
+	clients *ClientPool // SDK 客户端池，见 clients.go
+	cfg     *Config     // 网关配置，见 config.go
+
+	store *Store // 链下查询库，打开失败时为 nil
 )
 
 // 初始化 SDK，需要用到 配置文件：config.yaml
-func init() {
+// 在 main 中调用而不是放在 init 中，测试不需要连接区块链网络
+func initSDK() {
 	var err error
-	sdk, err = fabsdk.New(config.FromFile(configPath))
+	sdk, err = fabsdk.New(config.FromFile(cfg.SDKConfig))
 	if err != nil {
 		panic(err)
 	}
+	clients = NewClientPool(sdk)
 }
 
-// 区块链管理
-func manageBlockchain() {
-	// 表明身份
-	ctx := sdk.Context(fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := resmgmt.New(ctx) // resource management 资源管理包 resmgmt
+// 重建链下查询库：清空后从创世区块重新同步到当前高度
+// 监听服务运行中的进程需要先停止，重新启动后会从已同步的区块继续
+func rebuild() error {
+	s, err := OpenStore(cfg.Store, cfg.DefaultTarget())
 	if err != nil {
-		panic(err)
+		return err
 	}
-
-	// 具体操作
-	// SaveChannel 创建or更新通道
-	cli.SaveChannel(resmgmt.SaveChannelRequest{}, resmgmt.WithOrdererEndpoint("orderer.example.com"),
-		resmgmt.WithTargetEndpoints())
+	defer s.Close()
+	if err := s.Reset(); err != nil {
+		return err
+	}
+	if err := s.Sync(); err != nil {
+		return err
+	}
+	last, _, err := s.LastBlock()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("store rebuilt to block %d\n", last)
+	return nil
 }
 
 // 区块链查询  账本查询
-func queryBlockchain() *fab.BlockchainInfoResponse {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := ledger.New(ctx) // 实例化一个账本客户端
+// 区块和交易的查询见 explorer.go
+func queryBlockchain(t *Target) (*fab.BlockchainInfoResponse, error) {
+	cli, err := ledgerClient(t) // 账本客户端，来自客户端池
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	resp, err := cli.QueryInfo(ledger.WithTargetEndpoints("peer0.org1.example.com"))
-	if err != nil {
-		panic(err)
+	info, err := cli.QueryInfo(ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if isConnectionError(err) {
+		clients.Reset(t)
 	}
-
-	// fmt.Println(resp)
-
-	// // 1 QueryBlockByHash queries the ledger for block by block hash.
-	// cli.QueryBlockByHash(resp.BCI.CurrentBlockHash)
-	// // height, currentBlockHash, previousBlockHash := resp.BCI.Height, resp.BCI.CurrentBlockHash, resp.BCI.PreviousBlockHash
-
-	// // 2 从第0个块开始遍历
-	// for i := uint64(0); i <= resp.BCI.Height; i++ {
-	// 	cli.QueryBlock(i)
-	// }
-	// // 以上两种方式都可以实现区块链浏览器读取区块的功能
-
-	// high := resp.BCI.Height
-	// return height, currentBlockHash, previousBlockHash
-	return resp
+	return info, err
 }
 
 // 区块链交互
-func channelExecute(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := channel.New(ctx)
+// 客户端来自客户端池，背书节点的选择和故障转移见 selection.go；所有重试都失败且是连接错误时重建客户端
+func channelExecute(t *Target, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := clients.Channel(t)
 	if err != nil {
 		return channel.Response{}, err
 	}
 
 	// 状态更新，insert/update/delete
-	resp, err := cli.Execute(channel.Request{
-		ChaincodeID: chaincodeName,
-		Fcn:         fcn,
-		Args:        args,
-	}, channel.WithTargetEndpoints("peer0.org1.example.com"))
+	resp, err := invokeWithFailover(t, filter.EndorsingPeer, func(opts ...channel.RequestOption) (channel.Response, error) {
+		return cli.Execute(channel.Request{
+			ChaincodeID: t.Chaincode,
+			Fcn:         fcn,
+			Args:        args,
+		}, opts...)
+	})
 	if err != nil {
-		return channel.Response{}, err
+		if isConnectionError(err) {
+			clients.Reset(t)
+		}
+		// 交易提交后验证未通过时 resp 中带有交易id
+		return resp, err
 	}
 
-	// 链码事件监听
-	go func() {
-		// channel
-		reg, ccevt, err := cli.RegisterChaincodeEvent(chaincodeName, "eventname")
-		if err != nil {
+	// 链码事件和区块事件由监听服务统一处理，见 listener.go
+	return resp, nil
+}
+
+// 执行写交易并返回结果
+// 默认等待交易上链后返回；?async=true 时交易发送到排序节点后立即返回 202 和交易id，
+// 之后通过 GET /api/v1/transactions/{txid}/status 查询交易状态，见 txstatus.go
+func submitTx(ctx *gin.Context, fcn string, args [][]byte) {
+	async := false
+	if value := ctx.Query("async"); value != "" {
+		var err error
+		if async, err = strconv.ParseBool(value); err != nil {
+			respondErrorCode(ctx, ErrInvalidArgument, "async must be true or false")
 			return
 		}
-		defer cli.UnregisterChaincodeEvent(reg)
-
-		timeoutctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
-
-		for {
-			select {
-			case evt := <-ccevt:
-				fmt.Printf("received event of tx %s: %+v", resp.TransactionID, evt)
-			case <-timeoutctx.Done():
-				fmt.Println("event timeout, exit!")
-				return
-			}
-		}
-
-		// event
-		// eventcli, err := event.New(ctx)
-		// if err != nil {
-		// 	return
-		// }
-		// eventcli.RegisterChaincodeEvent(chaincodeName, "eventname")
-		// ... same as channel moudle
-		//
-	}()
+	}
 
-	// 交易状态事件监听
-	go func() {
-		eventcli, err := event.New(ctx)
+	if async {
+		resp, err := channelSubmit(target(ctx), fcn, args)
 		if err != nil {
+			respondTxError(ctx, resp, err)
 			return
 		}
-
-		reg, status, err := eventcli.RegisterTxStatusEvent(string(resp.TransactionID))
-		defer eventcli.Unregister(reg) // 注册必有注销
-
-		timeoutctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
-
-		for {
-			select {
-			case evt := <-status:
-				fmt.Printf("received event of tx %s: %+v", resp.TransactionID, evt)
-			case <-timeoutctx.Done():
-				fmt.Println("event timeout, exit!")
-				return
-			}
-		}
-	}()
-
-	return resp, nil
-}
-
-func channelQuery(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := channel.New(ctx)
-	if err != nil {
-		return channel.Response{}, err
+		respondAccepted(ctx, resp)
+		return
 	}
 
-	// 状态的查询，select
-	return cli.Query(channel.Request{
-		ChaincodeID: chaincodeName,
-		Fcn:         fcn,
-		Args:        args,
-	}, channel.WithTargetEndpoints("peer0.org1.example.com"))
-}
-
-// 事件监听
-func eventHandle() {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := event.New(ctx)
+	resp, err := channelExecute(target(ctx), fcn, args)
 	if err != nil {
-		panic(err)
+		respondTxError(ctx, resp, err)
+		return
 	}
+	respondTx(ctx, resp)
+}
 
-	// 交易状态事件		--区块链交互模块
-	// 链码事件 业务事件	--区块链交互模块
-
-	// 区块事件
-	reg, blkevent, err := cli.RegisterBlockEvent()
+func channelQuery(t *Target, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := clients.Channel(t)
 	if err != nil {
-		panic(err)
+		return channel.Response{}, err
 	}
-	defer cli.Unregister(reg)
 
-	timeoutctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
-	for {
-		select {
-		case evt := <-blkevent:
-			fmt.Printf("received a block, %+v", evt)
-		case <-timeoutctx.Done():
-			fmt.Println("event timeout, exit!")
-			return
-		}
+	// 状态的查询，select
+	resp, err := invokeWithFailover(t, filter.ChaincodeQuery, func(opts ...channel.RequestOption) (channel.Response, error) {
+		return cli.Query(channel.Request{
+			ChaincodeID: t.Chaincode,
+			Fcn:         fcn,
+			Args:        args,
+		}, opts...)
+	})
+	if isConnectionError(err) {
+		clients.Reset(t)
 	}
+	return resp, err
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI 3 文档
+// 路径和参数来自 apiRoutes()，请求体的字段来自 Customer、Collateral、Project 等请求结构体的 form/json 和 binding 标签，
+// 修改接口或请求结构体后需要重新生成 app/openapi.json：
+//
+//	go test -run TestOpenAPIDocument -update
+//
+// 前端和合作方可以用 openapi.json 生成客户端，如 openapi-generator generate -i openapi.json -g typescript-axios
+
+// 文档中 gin 路径参数 :name 转换为 {name}
+var (
+	pathParamPattern    = regexp.MustCompile(`:([A-Za-z]+)`)
+	docPathParamPattern = regexp.MustCompile(`{([A-Za-z]+)}`)
+)
+
+// 生成 OpenAPI 文档
+func openAPIDocument() map[string]interface{} {
+	paths := map[string]interface{}{}
+	schemas := map[string]interface{}{
+		"Response": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"code", "message"},
+			"properties": map[string]interface{}{
+				"code":    map[string]interface{}{"type": "string", "description": "错误码，成功时为 OK"},
+				"message": map[string]interface{}{"type": "string", "description": "错误描述，成功时为 success"},
+				"data":    map[string]interface{}{"description": "返回的数据，出错时为出错的字段等详细信息"},
+				"txId":    map[string]interface{}{"type": "string", "description": "写交易的交易id"},
+				"endorsers": map[string]interface{}{
+					"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "写交易的背书节点",
+				},
+			},
+		},
+	}
+
+	for _, route := range apiRoutes() {
+		docPath := route.DocPath
+		if docPath == "" {
+			docPath = pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		}
+
+		var params []interface{}
+		pathParams := map[string]bool{}
+		for _, match := range docPathParamPattern.FindAllStringSubmatch(docPath, -1) {
+			pathParams[match[1]] = true
+			params = append(params, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name": p.Name, "in": "query", "required": p.Required, "description": p.Description,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		responses := map[string]interface{}{
+			"200":     envelopeResponse("成功"),
+			"default": envelopeResponse("失败，HTTP 状态码由错误码决定"),
+		}
+		for _, p := range route.Query {
+			if p.Name == "async" {
+				responses["202"] = envelopeResponse("异步提交时交易已发送到排序节点，Location 为交易状态的地址")
+			}
+		}
+		operation := map[string]interface{}{
+			"summary":     route.Summary,
+			"tags":        []string{route.Tag},
+			"operationId": operationID(route.Method, docPath),
+			"responses":   responses,
+		}
+		if route.Public {
+			operation["security"] = []interface{}{}
+		} else {
+			operation["x-roles"] = route.roles()
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if route.Body != nil {
+			// 请求结构体都作为公共 schema，请求体中没有路径参数对应的字段时直接引用
+			name := reflect.TypeOf(route.Body).Name()
+			schemas[name] = bodySchema(route.Body)
+			var ref interface{} = map[string]interface{}{"$ref": "#/components/schemas/" + name}
+			if schema, removed := bodySchemaWithout(route.Body, pathParams); removed {
+				ref = schema
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json":                  map[string]interface{}{"schema": ref},
+					"application/x-www-form-urlencoded": map[string]interface{}{"schema": ref},
+				},
+			}
+		}
+
+		item, ok := paths[docPath].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[docPath] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Assets management gateway",
+			"version": "1.0.0",
+			"description": "资产管理链码的 REST 网关。响应统一为 Response 格式，请求体可以是 JSON 或表单。" +
+				"旧的动词式接口（如 /addCustomerInfo）已废弃，不在本文档中。" +
+				"启用认证时需要 /auth/login 返回的令牌或 API key，x-roles 为可以调用接口的角色，admin 可以调用全部接口。",
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": apiV1}},
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}, map[string]interface{}{"apiKey": []string{}}},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// 接口的 operationId，如 POST /customers/{id}/collaterals 为 postCustomersByIdCollaterals
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") {
+			segment = "By" + strings.Trim(segment, "{}")
+		}
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
+
+func envelopeResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Response"},
+			},
+		},
+	}
+}
+
+// 由请求结构体生成请求体的 schema
+func bodySchema(body interface{}) map[string]interface{} {
+	schema, _ := bodySchemaWithout(body, nil)
+	return schema
+}
+
+// 由请求结构体生成请求体的 schema，去掉路径参数对应的字段（路径参数优先于请求体），返回是否去掉了字段
+func bodySchemaWithout(body interface{}, pathParams map[string]bool) (map[string]interface{}, bool) {
+	t := reflect.TypeOf(body)
+	properties := map[string]interface{}{}
+	required := []string{}
+	removed := false
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("form"), ",")[0]
+		if pathParams[name] {
+			removed = true
+			continue
+		}
+		property := fieldSchema(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			switch {
+			case rule == "required":
+				required = append(required, name)
+			case strings.HasPrefix(rule, "len="):
+				n, _ := strconv.Atoi(strings.TrimPrefix(rule, "len="))
+				property["minLength"], property["maxLength"] = n, n
+			case rule == "datetime=2006-01-02":
+				property["format"] = "date"
+			case rule == "numeric":
+				property["pattern"] = `^[-+]?[0-9]+(\.[0-9]+)?$`
+			}
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, removed
+}
+
+// 字段类型对应的 schema，金额（json.Number）在 JSON 中可以是数字或字符串，文档中按字符串描述
+func fieldSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(json.Number("")) {
+		return map[string]interface{}{"type": "string", "format": "decimal"}
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": fieldSchema(t.Elem())}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// 返回 OpenAPI 文档
+func serveOpenAPI(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, openAPIDocument())
+}
+
+// Swagger UI 页面，静态资源从 CDN 加载
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Assets management gateway</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@3/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@3/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// 返回 Swagger UI 页面
+func serveSwaggerUI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "重新生成 openapi.json")
+
+const openAPIFile = "openapi.json"
+
+// 文档与 openapi.json 一致，接口或请求结构体变化后需要用 -update 重新生成并一起提交
+func TestOpenAPIDocument(t *testing.T) {
+	generated, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated = append(generated, '\n')
+
+	if *update {
+		if err := ioutil.WriteFile(openAPIFile, generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	published, err := ioutil.ReadFile(openAPIFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, published) {
+		t.Fatalf("%s is out of date, run go test -run TestOpenAPIDocument -update", openAPIFile)
+	}
+}
+
+// /api/v1 下注册的每个路由都在文档中，文档中的每个接口都已注册
+func TestOpenAPIRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registerRoutes(engine)
+
+	documented := map[string]bool{}
+	for _, route := range apiRoutes() {
+		documented[route.Method+" "+apiV1+route.Path] = true
+	}
+	registered := map[string]bool{}
+	for _, route := range engine.Routes() {
+		if !strings.HasPrefix(route.Path, apiV1+"/") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if !documented[key] {
+			t.Errorf("route %s is not in the OpenAPI document", key)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("documented route %s is not registered", key)
+		}
+	}
+
+	paths := openAPIDocument()["paths"].(map[string]interface{})
+	for _, route := range apiRoutes() {
+		path := route.DocPath
+		if path == "" {
+			path = pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		}
+		item, ok := paths[path].(map[string]interface{})
+		if !ok || item[strings.ToLower(route.Method)] == nil {
+			t.Errorf("%s %s is missing from paths", route.Method, path)
+		}
+	}
+}
+
+// 请求结构体的每个字段都有相同的 form 和 json 名称，并出现在文档的 schema 中
+func TestOpenAPIFields(t *testing.T) {
+	schemas := openAPIDocument()["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, body := range []interface{}{Customer{}, Collateral{}, CollateralRelease{}, CollateralTransfer{}, Project{}} {
+		typ := reflect.TypeOf(body)
+		schema, ok := schemas[typ.Name()].(map[string]interface{})
+		if !ok {
+			t.Errorf("schema %s is missing", typ.Name())
+			continue
+		}
+		properties := schema["properties"].(map[string]interface{})
+		if len(properties) != typ.NumField() {
+			t.Errorf("schema %s has %d properties, struct has %d fields", typ.Name(), len(properties), typ.NumField())
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			form := strings.Split(field.Tag.Get("form"), ",")[0]
+			jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+			if form == "" || form != jsonName {
+				t.Errorf("%s.%s: form name %q and json name %q must match", typ.Name(), field.Name, form, jsonName)
+			}
+			if properties[form] == nil {
+				t.Errorf("%s.%s: property %q is missing from the schema", typ.Name(), field.Name, form)
+			}
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 报表接口，数据来自链下查询库（见 store.go），不经过链码
+// 查询库随区块事件更新，结果可能比账本落后几个区块，响应中的 lastBlock 为已同步的最后一个区块号
+
+// ReportCustomer 报表中的客户
+type ReportCustomer struct {
+	ID           string `json:"id"`           //客户编号
+	Name         string `json:"name"`         //客户名称
+	Code         string `json:"code"`         //统一社会信用代码
+	Type         string `json:"type"`         //类型
+	Amount       string `json:"amount"`       //注册资本
+	Currency     string `json:"currency"`     //币种
+	Person       string `json:"person"`       //法人代表
+	Date         string `json:"date"`         //成立日期
+	BusinessDate string `json:"businessDate"` //营业期限
+	ApprovalDate string `json:"approvalDate"` //核准日期
+	Trade        string `json:"trade"`        //所属行业
+	Archived     bool   `json:"archived"`     //是否已归档
+	OwnerMSP     string `json:"ownerMsp"`     //所属组织
+	TxID         string `json:"txId"`         //最后一次修改的交易id
+	BlockNumber  uint64 `json:"blockNumber"`  //最后一次修改所在的区块
+	UpdatedAt    string `json:"updatedAt"`    //最后一次修改的时间
+}
+
+// ReportCollateral 报表中的押品
+type ReportCollateral struct {
+	Customer     string `json:"customer"`     //客户编号
+	CollateralID string `json:"collateralId"` //押品编号
+	Name         string `json:"name"`         //押品名称
+	Status       string `json:"status"`       //押品状态
+	TransferFrom string `json:"transferFrom"` //转入来源客户
+	TransferTo   string `json:"transferTo"`   //转出目标客户
+	TxID         string `json:"txId"`         //最后一次修改的交易id
+	BlockNumber  uint64 `json:"blockNumber"`  //最后一次修改所在的区块
+	UpdatedAt    string `json:"updatedAt"`    //最后一次修改的时间
+}
+
+// ReportProject 报表中的项目
+type ReportProject struct {
+	Customer    string `json:"customer"`    //客户编号
+	ProjectID   string `json:"projectId"`   //项目编号
+	Name        string `json:"name"`        //项目名称
+	Type        string `json:"type"`        //业务类型
+	Trade       string `json:"trade"`       //所属行业
+	Date        string `json:"date"`        //批复下达日
+	Approve     bool   `json:"approve"`     //审批是否通过
+	Part        bool   `json:"part"`        //是否成立有限合伙人
+	Invest      bool   `json:"invest"`      //是否有自有资金投资
+	Amount      string `json:"amount"`      //持有债券金额
+	Currency    string `json:"currency"`    //币种
+	CompanyType string `json:"companyType"` //被投资企业类型
+	TxID        string `json:"txId"`        //最后一次修改的交易id
+	BlockNumber uint64 `json:"blockNumber"` //最后一次修改所在的区块
+	UpdatedAt   string `json:"updatedAt"`   //最后一次修改的时间
+}
+
+// TradeSummary 按行业汇总的项目
+type TradeSummary struct {
+	Trade    string            `json:"trade"`    //所属行业
+	Projects int64             `json:"projects"` //项目数
+	Approved int64             `json:"approved"` //审批通过的项目数
+	Amounts  map[string]string `json:"amounts"`  //按币种汇总的持有债券金额
+}
+
+// 查询条件，按顺序拼接为 WHERE 子句
+type reportFilter struct {
+	conds []string
+	args  []interface{}
+}
+
+func (f *reportFilter) add(cond string, arg interface{}) {
+	f.conds = append(f.conds, cond)
+	f.args = append(f.args, arg)
+}
+
+func (f *reportFilter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// 查询库未打开时返回 503
+func reportStore(ctx *gin.Context) *Store {
+	if store == nil {
+		respondErrorCode(ctx, ErrUnavailable, "report store is not available")
+		return nil
+	}
+	return store
+}
+
+// 返回报表结果，附带已同步的最后一个区块号
+func respondReport(ctx *gin.Context, s *Store, records interface{}) {
+	last, ok, err := s.LastBlock()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp := gin.H{"records": records}
+	if ok {
+		resp["lastBlock"] = last
+	}
+	respondOK(ctx, resp)
+}
+
+// 解析布尔类型的查询参数，为空时不过滤
+func boolFilter(ctx *gin.Context, f *reportFilter, name, column string) bool {
+	value := ctx.Query(name)
+	if value == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "%s must be true or false", name)
+		return false
+	}
+	f.add(column+" = ?", b)
+	return true
+}
+
+// 金额列为 NULL（账本中的金额无法解析）时返回空字符串
+func nullAmount(v sql.NullInt64) string {
+	if !v.Valid {
+		return ""
+	}
+	return formatAmountE4(v.Int64)
+}
+
+// 汇总信息：客户、押品、项目的数量
+func reportSummary(ctx *gin.Context) {
+	s := reportStore(ctx)
+	if s == nil {
+		return
+	}
+
+	var customers, archived, projects, approved int64
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(archived), 0) FROM customers`).Scan(&customers, &archived)
+	if err == nil {
+		err = s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(approve), 0) FROM projects`).Scan(&projects, &approved)
+	}
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	collaterals := map[string]int64{}
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM collaterals GROUP BY status`)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		collaterals[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondReport(ctx, s, gin.H{
+		"customers":         customers,
+		"archivedCustomers": archived,
+		"collaterals":       collaterals,
+		"projects":          projects,
+		"approvedProjects":  approved,
+	})
+}
+
+// 客户列表，可按 trade、archived 过滤
+func reportCustomers(ctx *gin.Context) {
+	s := reportStore(ctx)
+	if s == nil {
+		return
+	}
+	f := &reportFilter{}
+	if trade := ctx.Query("trade"); trade != "" {
+		f.add("trade = ?", trade)
+	}
+	if !boolFilter(ctx, f, "archived", "archived") {
+		return
+	}
+
+	rows, err := s.db.Query(`SELECT id, name, code, type, amount_e4, currency, person, date, business_date,
+		approval_date, trade, archived, owner_msp, tx_id, block_number, updated_at FROM customers`+f.where()+` ORDER BY id`, f.args...)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer rows.Close()
+
+	records := []ReportCustomer{}
+	for rows.Next() {
+		var c ReportCustomer
+		var amount sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &c.Code, &c.Type, &amount, &c.Currency, &c.Person, &c.Date, &c.BusinessDate,
+			&c.ApprovalDate, &c.Trade, &c.Archived, &c.OwnerMSP, &c.TxID, &c.BlockNumber, &c.UpdatedAt); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		c.Amount = nullAmount(amount)
+		records = append(records, c)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondReport(ctx, s, records)
+}
+
+// 押品列表，可按 customer、status 过滤
+func reportCollaterals(ctx *gin.Context) {
+	s := reportStore(ctx)
+	if s == nil {
+		return
+	}
+	f := &reportFilter{}
+	if customer := ctx.Query("customer"); customer != "" {
+		f.add("customer = ?", customer)
+	}
+	if status := ctx.Query("status"); status != "" {
+		f.add("status = ?", status)
+	}
+
+	rows, err := s.db.Query(`SELECT customer, collateral_id, name, status, transfer_from, transfer_to,
+		tx_id, block_number, updated_at FROM collaterals`+f.where()+` ORDER BY customer, collateral_id`, f.args...)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer rows.Close()
+
+	records := []ReportCollateral{}
+	for rows.Next() {
+		var c ReportCollateral
+		if err := rows.Scan(&c.Customer, &c.CollateralID, &c.Name, &c.Status, &c.TransferFrom, &c.TransferTo,
+			&c.TxID, &c.BlockNumber, &c.UpdatedAt); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		records = append(records, c)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondReport(ctx, s, records)
+}
+
+// 项目过滤条件：customer、trade、approved、批复下达日 from/to（含两端，格式 2006-01-02）
+func projectFilter(ctx *gin.Context) (*reportFilter, bool) {
+	f := &reportFilter{}
+	if customer := ctx.Query("customer"); customer != "" {
+		f.add("customer = ?", customer)
+	}
+	if trade := ctx.Query("trade"); trade != "" {
+		f.add("trade = ?", trade)
+	}
+	if !boolFilter(ctx, f, "approved", "approve") {
+		return nil, false
+	}
+	if from := ctx.Query("from"); from != "" {
+		f.add("date >= ?", from)
+	}
+	if to := ctx.Query("to"); to != "" {
+		f.add("date <= ?", to)
+	}
+	return f, true
+}
+
+// 项目列表
+func reportProjects(ctx *gin.Context) {
+	s := reportStore(ctx)
+	if s == nil {
+		return
+	}
+	f, ok := projectFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := s.db.Query(`SELECT customer, project_id, name, type, trade, date, approve, part, invest, amount_e4,
+		currency, company_type, tx_id, block_number, updated_at FROM projects`+f.where()+` ORDER BY customer, project_id`, f.args...)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer rows.Close()
+
+	records := []ReportProject{}
+	for rows.Next() {
+		var p ReportProject
+		var amount sql.NullInt64
+		if err := rows.Scan(&p.Customer, &p.ProjectID, &p.Name, &p.Type, &p.Trade, &p.Date, &p.Approve, &p.Part, &p.Invest,
+			&amount, &p.Currency, &p.CompanyType, &p.TxID, &p.BlockNumber, &p.UpdatedAt); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		p.Amount = nullAmount(amount)
+		records = append(records, p)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondReport(ctx, s, records)
+}
+
+// 按行业汇总项目数和持有债券金额，过滤条件与项目列表相同
+func reportProjectsByTrade(ctx *gin.Context) {
+	s := reportStore(ctx)
+	if s == nil {
+		return
+	}
+	f, ok := projectFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := s.db.Query(`SELECT trade, currency, COUNT(*), COALESCE(SUM(approve), 0), COALESCE(SUM(amount_e4), 0)
+		FROM projects`+f.where()+` GROUP BY trade, currency ORDER BY trade, currency`, f.args...)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer rows.Close()
+
+	records := []*TradeSummary{}
+	byTrade := map[string]*TradeSummary{}
+	for rows.Next() {
+		var trade, currency string
+		var count, approved, amount int64
+		if err := rows.Scan(&trade, &currency, &count, &approved, &amount); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		summary, ok := byTrade[trade]
+		if !ok {
+			summary = &TradeSummary{Trade: trade, Amounts: map[string]string{}}
+			byTrade[trade] = summary
+			records = append(records, summary)
+		}
+		summary.Projects += count
+		summary.Approved += approved
+		summary.Amounts[currency] = formatAmountE4(amount)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	respondReport(ctx, s, records)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 接口前缀
+const apiV1 = "/api/v1"
+
+// APIRoute /api/v1 下的一个接口，同时用于注册路由和生成 OpenAPI 文档（见 openapi.go）
+type APIRoute struct {
+	Method  string          //HTTP 方法
+	Path    string          //gin 路由，相对于 /api/v1
+	Handler gin.HandlerFunc //处理函数
+	Summary string          //接口说明
+	Tag     string          //分组
+	Query   []APIParam      //query 参数
+	Body    interface{}     //请求体对应的结构体，没有请求体时为 nil
+	DocPath string          //文档中的路径，gin 路由无法注册该路径时指定，由处理函数检查路径（见 routes_test.go）
+	Roles   []string        //可以调用的角色，未指定时按 HTTP 方法决定，见 auth.go
+	Public  bool            //不需要认证，如登录
+}
+
+// 接口可以使用的角色
+func (r APIRoute) roles() []string {
+	if r.Roles != nil {
+		return r.Roles
+	}
+	return methodRoles(r.Method)
+}
+
+// APIParam query 参数
+type APIParam struct {
+	Name        string //参数名
+	Description string //参数说明
+	Required    bool   //是否必填
+}
+
+// 分页和历史查询时间范围的 query 参数
+var (
+	paginationParams = []APIParam{
+		{Name: "pageSize", Description: "每页记录数，指定时分页返回"},
+		{Name: "bookmark", Description: "上一页返回的书签"},
+	}
+	timeRangeParams = []APIParam{
+		{Name: "from", Description: "起始时间，RFC3339 时间或日期（2006-01-02）"},
+		{Name: "to", Description: "结束时间，RFC3339 时间或日期（2006-01-02，包含当天）"},
+	}
+	historyParams = append(append([]APIParam{}, paginationParams...), timeRangeParams...)
+)
+
+// 写交易的 query 参数，见 submitTx
+var asyncParams = []APIParam{
+	{Name: "async", Description: "为 true 时交易发送到排序节点后立即返回 202，不等待上链"},
+}
+
+// /api/v1 下的全部接口
+func apiRoutes() []APIRoute {
+	return []APIRoute{
+		{Method: "GET", Path: "/chain", Handler: queryBlockchainInfo, Tag: "blocks", Summary: "查询区块链信息"},
+
+		{Method: "GET", Path: "/customers", Handler: queryCustomers, Tag: "customers", Summary: "按统一社会信用代码或客户名称查询客户",
+			Query: []APIParam{{Name: "code", Description: "统一社会信用代码"}, {Name: "name", Description: "客户名称"}}},
+		{Method: "POST", Path: "/customers", Handler: addCustomer, Tag: "customers", Summary: "新建客户，客户编号在请求体中", Query: asyncParams, Body: Customer{}},
+		{Method: "GET", Path: "/customers/:id", Handler: queryCustomerInfo, Tag: "customers", Summary: "查询客户信息（含押品和项目）"},
+		{Method: "POST", Path: "/customers/:id", Handler: addCustomer, Tag: "customers", Summary: "新建客户", Query: asyncParams, Body: Customer{}},
+		{Method: "PUT", Path: "/customers/:id", Handler: updateCustomer, Tag: "customers", Summary: "修改客户信息", Query: asyncParams, Body: Customer{}},
+		{Method: "DELETE", Path: "/customers/:id", Handler: archiveCustomer, Tag: "customers", Summary: "归档客户", Query: asyncParams},
+		{Method: "POST", Path: "/customers/:id/claim", Handler: claimCustomer, Tag: "customers", Summary: "认领没有所属组织的早期客户，需要 dataAdmin 角色的链码身份", Query: asyncParams, Roles: adminRoles},
+		{Method: "GET", Path: "/customers/:id/asOf", Handler: queryCustomerAsOf, Tag: "customers", Summary: "查询客户在指定时间点的信息",
+			Query: []APIParam{{Name: "timestamp", Description: "RFC3339 时间或日期（2006-01-02，表示当天结束时）", Required: true}}, Roles: auditRoles},
+		{Method: "GET", Path: "/customers/:id/history", Handler: getHistoryCustomer, Tag: "history", Summary: "客户历史信息查询", Query: historyParams, Roles: auditRoles},
+		{Method: "GET", Path: "/customers/:id/history/collaterals", Handler: getHistoryCollateral, Tag: "history", Summary: "客户名下全部押品的变更历史", Query: historyParams, Roles: auditRoles},
+		{Method: "GET", Path: "/customers/:id/history/projects", Handler: getHistoryProject, Tag: "history", Summary: "客户名下全部项目的变更历史", Query: historyParams, Roles: auditRoles},
+
+		{Method: "GET", Path: "/customers/:id/collaterals", Handler: listCollaterals, Tag: "collaterals", Summary: "客户名下押品查询"},
+		{Method: "POST", Path: "/customers/:id/collaterals", Handler: addCollateral, Tag: "collaterals", Summary: "添加押品，押品编号在请求体中", Query: asyncParams, Body: Collateral{}},
+		{Method: "GET", Path: "/customers/:id/collaterals/:collateralId", Handler: queryCollateral, Tag: "collaterals", Summary: "查询押品"},
+		{Method: "POST", Path: "/customers/:id/collaterals/:collateralId", Handler: addCollateral, Tag: "collaterals", Summary: "添加押品", Query: asyncParams, Body: Collateral{}},
+		{Method: "POST", Path: "/customers/:id/collaterals/:collateralId/release", Handler: releaseCollateral, Tag: "collaterals", Summary: "押品解押", Query: asyncParams, Body: CollateralRelease{}},
+		{Method: "POST", Path: "/customers/:id/collaterals/:collateralId/transfer", Handler: transferCollateral, Tag: "collaterals", Summary: "押品转让", Query: asyncParams, Body: CollateralTransfer{}},
+		{Method: "GET", Path: "/customers/:id/collaterals/:collateralId/history", Handler: getHistoryCollateral, Tag: "history", Summary: "押品变更历史查询", Query: historyParams, Roles: auditRoles},
+
+		// 链码不支持修改或删除项目，因此没有 PUT 和 DELETE
+		{Method: "GET", Path: "/customers/:id/projects", Handler: listProjects, Tag: "projects", Summary: "客户名下项目查询"},
+		{Method: "POST", Path: "/customers/:id/projects", Handler: addProject, Tag: "projects", Summary: "添加项目，项目编号在请求体中", Query: asyncParams, Body: Project{}},
+		{Method: "GET", Path: "/customers/:id/projects/:projectId", Handler: queryProject, Tag: "projects", Summary: "查询项目"},
+		{Method: "POST", Path: "/customers/:id/projects/:projectId", Handler: addProject, Tag: "projects", Summary: "添加项目", Query: asyncParams, Body: Project{}},
+		{Method: "GET", Path: "/customers/:id/projects/:projectId/history", Handler: getHistoryProject, Tag: "history", Summary: "项目历史信息查询", Query: historyParams, Roles: auditRoles},
+
+		{Method: "GET", Path: "/search", Handler: search, Tag: "search", Summary: "富查询，需要 CouchDB 作为状态数据库",
+			Query: append([]APIParam{
+				{Name: "type", Description: "project 或 customer，未指定 query 时必填"},
+				{Name: "query", Description: "CouchDB 查询语句"},
+				{Name: "trade", Description: "项目所属行业"},
+				{Name: "approved", Description: "项目审批是否通过"},
+				{Name: "from", Description: "批复下达日起始日期"},
+				{Name: "to", Description: "批复下达日结束日期"},
+				{Name: "minCapital", Description: "注册资本下限"},
+				{Name: "currency", Description: "注册资本币种"},
+			}, paginationParams...)},
+
+		{Method: "GET", Path: "/reports/summary", Handler: reportSummary, Tag: "reports", Summary: "汇总信息"},
+		{Method: "GET", Path: "/reports/customers", Handler: reportCustomers, Tag: "reports", Summary: "客户报表",
+			Query: []APIParam{{Name: "trade", Description: "所属行业"}, {Name: "archived", Description: "是否已归档"}}},
+		{Method: "GET", Path: "/reports/collaterals", Handler: reportCollaterals, Tag: "reports", Summary: "押品报表",
+			Query: []APIParam{{Name: "customer", Description: "客户编号"}, {Name: "status", Description: "押品状态"}}},
+		{Method: "GET", Path: "/reports/projects", Handler: reportProjects, Tag: "reports", Summary: "项目报表", Query: projectReportParams},
+		{Method: "GET", Path: "/reports/projects/byTrade", Handler: reportProjectsByTrade, Tag: "reports", Summary: "按行业汇总项目", Query: projectReportParams},
+
+		{Method: "GET", Path: "/blocks", Handler: queryBlocks, Tag: "blocks", Summary: "按区间查询区块",
+			Query: []APIParam{{Name: "from", Description: "起始区块号，默认为 0"}, {Name: "to", Description: "结束区块号，默认为最新区块"}}},
+		{Method: "GET", Path: "/blocks/:number", Handler: queryBlockByNumber, Tag: "blocks", Summary: "按区块号查询区块"},
+		{Method: "GET", Path: "/blocks/:number/:hash", Handler: queryBlockByHash, Tag: "blocks", Summary: "按区块哈希查询区块", DocPath: "/blocks/hash/{hash}"},
+		{Method: "GET", Path: "/transactions/:txid", Handler: queryTransaction, Tag: "blocks", Summary: "按交易id查询交易"},
+		{Method: "GET", Path: "/transactions/:txid/status", Handler: queryTransactionStatus, Tag: "blocks", Summary: "查询交易状态：pending、valid 或 invalid"},
+
+		// 用户身份，见 identities.go；已登记的身份可以在请求头 X-Identity 中使用
+		{Method: "GET", Path: "/identities", Handler: listIdentities, Tag: "identities", Summary: "本地钱包中的身份", Roles: adminRoles},
+		{Method: "POST", Path: "/identities", Handler: registerIdentity, Tag: "identities", Summary: "在 Fabric CA 注册用户，返回登记密码", Body: IdentityRegistration{}, Roles: adminRoles},
+		{Method: "GET", Path: "/identities/:name", Handler: queryIdentity, Tag: "identities", Summary: "查询身份", Roles: adminRoles},
+		{Method: "POST", Path: "/identities/:name/enroll", Handler: enrollIdentity, Tag: "identities", Summary: "用登记密码登记证书", Body: IdentityEnrollment{}, Roles: adminRoles},
+		{Method: "POST", Path: "/identities/:name/reenroll", Handler: reenrollIdentity, Tag: "identities", Summary: "重新登记证书", Roles: adminRoles},
+		{Method: "POST", Path: "/identities/:name/revoke", Handler: revokeIdentity, Tag: "identities", Summary: "吊销身份的证书", Body: IdentityRevocation{}, Roles: adminRoles},
+
+		// 通道管理，见 channels.go
+		{Method: "POST", Path: "/admin/channels", Handler: createChannelHandler, Tag: "admin", Summary: "用 channel.tx 创建通道", Body: ChannelCreation{}, Roles: adminRoles},
+		{Method: "POST", Path: "/admin/channels/:channel/peers", Handler: joinChannelHandler, Tag: "admin", Summary: "节点加入通道，返回每个节点的结果", Body: ChannelJoin{}, Roles: adminRoles},
+		{Method: "POST", Path: "/admin/channels/:channel/anchors", Handler: updateAnchorPeersHandler, Tag: "admin", Summary: "用 anchors.tx 更新锚节点", Body: AnchorPeersUpdate{}, Roles: adminRoles},
+		{Method: "GET", Path: "/admin/peers/:peer/channels", Handler: joinedChannelsHandler, Tag: "admin", Summary: "查询节点已加入的通道", Roles: adminRoles},
+
+		// 认证，见 auth.go
+		{Method: "POST", Path: "/auth/login", Handler: login, Tag: "auth", Summary: "用户名和密码换取令牌", Body: LoginRequest{}, Public: true},
+		{Method: "GET", Path: "/auth/me", Handler: whoami, Tag: "auth", Summary: "当前调用方的角色和身份"},
+	}
+}
+
+// 项目报表的 query 参数
+var projectReportParams = []APIParam{
+	{Name: "customer", Description: "客户编号"},
+	{Name: "trade", Description: "所属行业"},
+	{Name: "approved", Description: "审批是否通过"},
+	{Name: "from", Description: "批复下达日起始日期"},
+	{Name: "to", Description: "批复下达日结束日期"},
+}
+
+// 注册路由
+// /api/v1 下为按资源组织的接口；旧的动词式接口保留为 /api/v1 对应接口的别名，
+// 响应头中带有 Deprecation 和指向新接口的 Link，新的客户端应改用 /api/v1
+// 启用认证时先认证并按角色授权（见 auth.go），再选择调用目标
+func registerRoutes(engine *gin.Engine) {
+	v1 := engine.Group(apiV1)
+	for _, route := range apiRoutes() {
+		if route.Public {
+			v1.Handle(route.Method, route.Path, selectTarget, route.Handler)
+			continue
+		}
+		v1.Handle(route.Method, route.Path, authorize(route.roles()), selectTarget, route.Handler)
+	}
+
+	// 接口文档
+	engine.GET("/openapi.json", serveOpenAPI) //OpenAPI 3 文档
+	engine.GET("/swagger", serveSwaggerUI)    //Swagger UI
+
+	// 旧接口，参数在 query 或表单中，按 HTTP 方法授权，历史和时间点查询与 /api/v1 相同需要 auditor
+	legacy := engine.Group("", authorizeMethod, selectTarget)
+	history := authorize(auditRoles)
+	legacy.GET("/getChainInfo", deprecated("/chain"), queryBlockchainInfo)                                                    //查询区块链信息
+	legacy.POST("/addCustomerInfo", deprecated("/customers"), addCustomer)                                                    //添加客户信息
+	legacy.POST("/customer", deprecated("/customers"), addCustomer)                                                           //新建客户信息
+	legacy.PUT("/customer", deprecated("/customers/{id}"), updateCustomer)                                                    //修改客户信息
+	legacy.DELETE("/customer", deprecated("/customers/{id}"), archiveCustomer)                                                //归档客户
+	legacy.POST("/addCollateralInfo", deprecated("/customers/{id}/collaterals"), addCollateral)                               //添加押品
+	legacy.POST("/releaseCollateral", deprecated("/customers/{id}/collaterals/{collateralId}/release"), releaseCollateral)    //押品解押
+	legacy.POST("/transferCollateral", deprecated("/customers/{id}/collaterals/{collateralId}/transfer"), transferCollateral) //押品转让
+	legacy.POST("/addProjectInfo", deprecated("/customers/{id}/projects"), addProject)                                        //添加项目
+	legacy.GET("/getCustomerInfo", deprecated("/customers/{id}"), queryCustomerInfo)                                          //查询客户信息
+	legacy.GET("/customer/asOf", deprecated("/customers/{id}/asOf"), history, queryCustomerAsOf)                              //查询客户在指定时间点的信息
+	legacy.GET("/getCustomerById", deprecated("/customers/{id}"), queryCustomerByID)                                          //按客户编号查询客户
+	legacy.GET("/getCustomerByCode", deprecated("/customers?code={code}"), queryCustomerByCode)                               //按统一社会信用代码查询客户
+	legacy.GET("/getCustomersByName", deprecated("/customers?name={name}"), queryCustomersByName)                             //按客户名称查询客户
+	legacy.GET("/getHistoryCustomerInfo", deprecated("/customers/{id}/history"), history, getHistoryCustomer)                 //客户历史信息查询
+	legacy.GET("/getHistoryCollateralInfo", deprecated("/customers/{id}/history/collaterals"), history, getHistoryCollateral) //押品变更历史查询
+	legacy.GET("/getHistoryProjectInfo", deprecated("/customers/{id}/history/projects"), history, getHistoryProject)          //项目历史信息查询
+	legacy.GET("/listProjectsByCustomer", deprecated("/customers/{id}/projects"), listProjects)                               //客户名下项目查询
+	legacy.GET("/search", deprecated("/search"), search)                                                                      //富查询
+	legacy.GET("/reports/summary", deprecated("/reports/summary"), reportSummary)                                             //汇总信息
+	legacy.GET("/reports/customers", deprecated("/reports/customers"), reportCustomers)                                       //客户报表
+	legacy.GET("/reports/collaterals", deprecated("/reports/collaterals"), reportCollaterals)                                 //押品报表
+	legacy.GET("/reports/projects", deprecated("/reports/projects"), reportProjects)                                          //项目报表
+	legacy.GET("/reports/projects/byTrade", deprecated("/reports/projects/byTrade"), reportProjectsByTrade)                   //按行业汇总项目
+	legacy.GET("/blocks", deprecated("/blocks"), queryBlocks)                                                                 //按区间查询区块
+	legacy.GET("/blocks/:number", deprecated("/blocks/{number}"), queryBlockByNumber)                                         //按区块号查询区块
+	legacy.GET("/blocks/:number/:hash", deprecated("/blocks/hash/{hash}"), queryBlockByHash)                                  //按区块哈希查询区块
+	legacy.GET("/transactions/:txid", deprecated("/transactions/{txid}"), queryTransaction)                                   //按交易id查询交易
+}
+
+// 旧接口的中间件，在响应头中标记接口已废弃，并给出 /api/v1 中对应的接口
+func deprecated(successor string) gin.HandlerFunc {
+	link := "<" + apiV1 + successor + `>; rel="successor-version"`
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", link)
+		ctx.Next()
+	}
+}
+
+// 请求头中指定调用目标的通道、链码和身份，未指定时使用配置中的默认值，见 config.go
+const (
+	headerChannel   = "X-Channel"
+	headerChaincode = "X-Chaincode"
+	headerIdentity  = "X-Identity"
+)
+
+// 按请求头选择调用目标，请求头中的名称未配置时返回 400
+// 启用认证时身份为调用方对应的身份，只有 admin 可以用 X-Identity 指定其他身份
+func selectTarget(ctx *gin.Context) {
+	identity := ctx.GetHeader(headerIdentity)
+	if p := principal(ctx); p != nil {
+		switch {
+		case identity == "":
+			identity = p.Identity
+		case identity != p.Identity && p.Role != RoleAdmin:
+			deny(ctx, p.Name, ErrAccessDenied, fmt.Sprintf("%s cannot act as identity %s", p.Name, identity))
+			return
+		}
+	}
+	t, err := cfg.Target(ctx.GetHeader(headerChannel), ctx.GetHeader(headerChaincode), identity)
+	if err != nil {
+		respondErrorCode(ctx, ErrInvalidArgument, "%s", err)
+		return
+	}
+	ctx.Set("target", t)
+	ctx.Next()
+}
+
+// 本次请求的调用目标
+func target(ctx *gin.Context) *Target {
+	return ctx.MustGet("target").(*Target)
+}
+
+// 读取参数，优先取路径参数（/api/v1），没有时取 query 参数（旧接口）
+func param(ctx *gin.Context, name string) string {
+	if value := ctx.Param(name); value != "" {
+		return value
+	}
+	return ctx.Query(name)
+}
+
+// 解析请求体（表单或 JSON，按 Content-Type 决定），路径参数按 form 标签写入同名字段并优先于请求体
+// 路径参数在解析前先写入一次，使 binding:"required" 的校验可以通过
+func bindRequest(ctx *gin.Context, req interface{}) error {
+	setPathParams(ctx, req)
+	if err := ctx.ShouldBind(req); err != nil {
+		return err
+	}
+	setPathParams(ctx, req)
+	return nil
+}
+
+func setPathParams(ctx *gin.Context, req interface{}) {
+	if len(ctx.Params) == 0 {
+		return
+	}
+	v := reflect.ValueOf(req).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("form"), ",")[0]
+		if value, ok := ctx.Params.Get(name); ok && v.Field(i).Kind() == reflect.String {
+			v.Field(i).SetString(value)
+		}
+	}
+}
+
+// 按统一社会信用代码或客户名称查询客户
+func queryCustomers(ctx *gin.Context) {
+	switch {
+	case ctx.Query("code") != "":
+		queryCustomerByCode(ctx)
+	case ctx.Query("name") != "":
+		queryCustomersByName(ctx)
+	default:
+		respondErrorCode(ctx, ErrInvalidArgument, "code or name is required")
+	}
+}
+
+// 客户信息中的押品和项目，与链码 getCustomerInfo 的返回值一致
+type customerRecords struct {
+	CollateralInfo []map[string]interface{} `json:"collateralInfo"`
+	ProjectInfos   []map[string]interface{} `json:"projectInfos"`
+}
+
+// 查询客户信息，链码没有单独查询押品和项目的函数，从客户信息中取出
+func queryCustomerRecords(ctx *gin.Context) (*customerRecords, bool) {
+	resp, err := channelQuery(target(ctx), "getCustomerInfo", [][]byte{
+		[]byte(param(ctx, "id")),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return nil, false
+	}
+	records := new(customerRecords)
+	if err := json.Unmarshal(resp.Payload, records); err != nil {
+		respondError(ctx, err)
+		return nil, false
+	}
+	return records, true
+}
+
+// 查询客户名下押品
+func listCollaterals(ctx *gin.Context) {
+	records, ok := queryCustomerRecords(ctx)
+	if !ok {
+		return
+	}
+	if records.CollateralInfo == nil {
+		records.CollateralInfo = []map[string]interface{}{}
+	}
+	respondOK(ctx, records.CollateralInfo)
+}
+
+// 查询押品
+func queryCollateral(ctx *gin.Context) {
+	records, ok := queryCustomerRecords(ctx)
+	if !ok {
+		return
+	}
+	collateralID := param(ctx, "collateralId")
+	for _, collateral := range records.CollateralInfo {
+		if collateral["collateralId"] == collateralID {
+			respondOK(ctx, collateral)
+			return
+		}
+	}
+	respondErrorCode(ctx, ErrNotFound, "collateral %s not found", collateralID)
+}
+
+// 查询项目
+func queryProject(ctx *gin.Context) {
+	records, ok := queryCustomerRecords(ctx)
+	if !ok {
+		return
+	}
+	projectID := param(ctx, "projectId")
+	for _, project := range records.ProjectInfos {
+		if project["projectId"] == projectID {
+			respondOK(ctx, project)
+			return
+		}
+	}
+	respondErrorCode(ctx, ErrNotFound, "project %s not found", projectID)
+}
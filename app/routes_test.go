@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 使用默认配置注册全部路由，未启用认证
+func testEngine(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg = defaultConfig()
+	engine := gin.New()
+	registerRoutes(engine)
+	return engine
+}
+
+// 发送请求，返回状态码和响应中的错误码
+func serve(engine *gin.Engine, req *http.Request) (int, ErrorCode) {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp.Code
+}
+
+// 文档路径与 gin 路由不同的接口，文档中的路径能到达处理函数
+func TestDocPathServed(t *testing.T) {
+	engine := testEngine(t)
+	for _, route := range apiRoutes() {
+		if route.DocPath == "" {
+			continue
+		}
+		// 路径参数取不合法的值，处理函数在访问网络之前返回参数错误
+		path := apiV1 + docPathParamPattern.ReplaceAllString(route.DocPath, "zz")
+		status, code := serve(engine, httptest.NewRequest(route.Method, path, nil))
+		if status != http.StatusBadRequest || code != ErrInvalidArgument {
+			t.Errorf("%s %s = %d %s, want 400 %s", route.Method, path, status, code, ErrInvalidArgument)
+		}
+	}
+}
+
+// /blocks/{number}/{hash} 只用于承载 /blocks/hash/{hash}，其他路径返回 404
+func TestBlockByHashRoute(t *testing.T) {
+	engine := testEngine(t)
+	status, code := serve(engine, httptest.NewRequest("GET", apiV1+"/blocks/7/abcd", nil))
+	if status != http.StatusNotFound || code != ErrNotFound {
+		t.Errorf("GET /blocks/7/abcd = %d %s, want 404 %s", status, code, ErrNotFound)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/filter"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config/endpoint"
+)
+
+// 背书节点的选择和故障转移
+// 通道配置 discovery: false 时背书节点固定为配置中的 peers，所有节点都需要背书成功；
+// discovery: true 时由 SDK 通过服务发现选择满足链码背书策略的节点组合（如需要 Org2 背书时选中 org2 的节点），
+// 在满足策略的节点中按区块高度和轮询分摊请求，排序和负载均衡方式见 config.yaml 中通道的 policies.selection。
+// 背书时连接失败的节点在 peerCooldown 内不再选择，并换一组节点重试，最多 maxEndorseAttempts 次。
+// 背书的节点在响应的 endorsers 中返回。
+
+const (
+	maxEndorseAttempts = 3                // 背书失败时最多尝试的次数
+	peerCooldown       = 30 * time.Second // 连接失败的节点暂停选择的时间
+)
+
+// PeerHealth 节点的连接状态，按节点地址记录
+// 节点的 URL 带有 grpcs:// 等前缀，背书错误和背书响应中的地址不带前缀，统一按不带前缀的地址记录
+type PeerHealth struct {
+	mu   sync.Mutex
+	down map[string]time.Time // 节点地址 -> 恢复选择的时间
+}
+
+// 所有通道共用，同一个节点在各通道上的连接状态相同
+var peerHealth = &PeerHealth{down: map[string]time.Time{}}
+
+// Healthy 节点是否可以选择
+func (h *PeerHealth) Healthy(url string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	until, ok := h.down[endpoint.ToAddress(url)]
+	if !ok {
+		return true
+	}
+	if time.Now().After(until) {
+		delete(h.down, endpoint.ToAddress(url))
+		return true
+	}
+	return false
+}
+
+// Fail 记录连接失败的节点
+func (h *PeerHealth) Fail(urls ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	until := time.Now().Add(peerCooldown)
+	for _, url := range urls {
+		h.down[endpoint.ToAddress(url)] = until
+	}
+}
+
+// Succeed 记录背书成功的节点
+func (h *PeerHealth) Succeed(urls ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, url := range urls {
+		delete(h.down, endpoint.ToAddress(url))
+	}
+}
+
+// 在节点角色的基础上过滤掉暂停选择的节点
+type healthFilter struct {
+	roles fab.TargetFilter
+}
+
+func (f *healthFilter) Accept(peer fab.Peer) bool {
+	return f.roles.Accept(peer) && peerHealth.Healthy(peer.URL())
+}
+
+// 选择节点的请求参数，role 为节点需要的角色（背书或链码查询）
+func targetOptions(t *Target, role filter.EndpointType) ([]channel.RequestOption, error) {
+	if !t.Discovery {
+		return []channel.RequestOption{channel.WithTargetEndpoints(t.Peers...)}, nil
+	}
+	chCtx, err := clients.ChannelContext(t)
+	if err != nil {
+		return nil, err
+	}
+	return []channel.RequestOption{
+		channel.WithTargetFilter(&healthFilter{roles: filter.NewEndpointFilter(chCtx, role)}),
+	}, nil
+}
+
+// 选择节点并调用，背书时节点连接失败则记录该节点并重试，成功时记录背书的节点
+// call 只在背书阶段失败时重试：写交易发送到排序节点之后的错误不会重试，避免重复提交
+func invokeWithFailover(t *Target, role filter.EndpointType, call func(opts ...channel.RequestOption) (channel.Response, error)) (channel.Response, error) {
+	opts, err := targetOptions(t, role)
+	if err != nil {
+		return channel.Response{}, err
+	}
+
+	var resp channel.Response
+	for attempt := 1; ; attempt++ {
+		resp, err = call(opts...)
+		if err == nil {
+			peerHealth.Succeed(endorsers(resp.Responses)...)
+			return resp, nil
+		}
+		// 已取得背书时错误来自排序或提交阶段，交易可能已经发送到排序节点
+		if len(resp.Responses) > 0 {
+			return resp, err
+		}
+		failed, retry := endorsementFailure(err)
+		peerHealth.Fail(failed...)
+		if !retry || attempt == maxEndorseAttempts {
+			return resp, err
+		}
+	}
+}
+
+// 从背书的错误中找出连接失败的节点地址，返回是否可以换节点重试
+// 只用于背书阶段的错误，gRPC 传输错误只可能来自背书节点；多个节点同时出错时，各节点的错误在 Details 中
+func endorsementFailure(err error) ([]string, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+	var failed []string
+	retry := false
+	switch {
+	case s.Group == status.EndorserClientStatus && s.Code == status.ConnectionFailed.ToInt32():
+		// 连接失败时 Details 中为节点地址
+		for _, detail := range s.Details {
+			if url, ok := detail.(string); ok {
+				failed = append(failed, url)
+			}
+		}
+		retry = true
+	case s.Group == status.GRPCTransportStatus:
+		retry = true
+	}
+	for _, detail := range s.Details {
+		if detailErr, ok := detail.(error); ok {
+			urls, detailRetry := endorsementFailure(detailErr)
+			failed = append(failed, urls...)
+			retry = retry || detailRetry
+		}
+	}
+	return failed, retry
+}
+
+// 背书的节点地址
+func endorsers(responses []*fab.TransactionProposalResponse) []string {
+	var urls []string
+	for _, r := range responses {
+		urls = append(urls, r.Endorser)
+	}
+	return urls
+}
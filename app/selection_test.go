@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/filter"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// 背书阶段连接失败时换节点重试，连接失败的节点暂停选择
+func TestInvokeWithFailoverRetriesEndorsement(t *testing.T) {
+	const url = "peer0.test.example.com:7051"
+	defer peerHealth.Succeed(url)
+
+	calls := 0
+	_, err := invokeWithFailover(&Target{Peers: []string{"peer0"}}, filter.EndorsingPeer, func(opts ...channel.RequestOption) (channel.Response, error) {
+		calls++
+		return channel.Response{}, status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection refused", []interface{}{url})
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != maxEndorseAttempts {
+		t.Errorf("called %d times, want %d", calls, maxEndorseAttempts)
+	}
+	if peerHealth.Healthy(url) {
+		t.Errorf("%s should be paused after connection failure", url)
+	}
+}
+
+// 背书完成后排序节点的传输错误不重试，避免同一笔写交易重复提交
+func TestInvokeWithFailoverDoesNotRetryOrderer(t *testing.T) {
+	calls := 0
+	endorsed := channel.Response{Responses: []*fab.TransactionProposalResponse{{Endorser: "peer0.test.example.com:7051"}}}
+	_, err := invokeWithFailover(&Target{Peers: []string{"peer0"}}, filter.EndorsingPeer, func(opts ...channel.RequestOption) (channel.Response, error) {
+		calls++
+		transport := status.New(status.GRPCTransportStatus, 14, "transport is closing", nil)
+		return endorsed, errors.Wrap(errors.Wrap(transport, "calling orderer 'orderer.example.com:7050' failed"), "CreateAndSendTransaction failed")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("called %d times, want 1", calls)
+	}
+}
+
+// 背书错误中的节点地址不带协议前缀，按节点 URL 过滤时同样生效
+func TestPeerHealthURL(t *testing.T) {
+	const address = "peer1.test.example.com:7051"
+	defer peerHealth.Succeed(address)
+
+	peerHealth.Fail(address)
+	if peerHealth.Healthy("grpcs://" + address) {
+		t.Errorf("grpcs://%s should be paused after %s failed", address, address)
+	}
+	peerHealth.Succeed("grpcs://" + address)
+	if !peerHealth.Healthy(address) {
+		t.Errorf("%s should be selectable after it succeeded", address)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	_ "github.com/mattn/go-sqlite3" // SQLite 驱动
+)
+
+// 链下查询库
+// 从账本的区块中解析 assetscc 的写集，把客户、押品、项目的最新状态写入 SQLite，供报表查询使用。
+// 启动时先用账本客户端补齐缺少的区块，之后作为监听服务的区块处理器随新区块更新；
+// 每个区块在一个数据库事务中写入，并记录已同步的区块号，重复收到的区块直接跳过。
+
+// 建表语句
+// 金额按万分之一为单位存为整数（amount_e4），汇总时没有精度损失
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS sync_state (
+	id         INTEGER PRIMARY KEY CHECK (id = 1),
+	last_block INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS customers (
+	id            TEXT PRIMARY KEY,
+	name          TEXT NOT NULL,
+	code          TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	amount_e4     INTEGER,
+	currency      TEXT NOT NULL,
+	person        TEXT NOT NULL,
+	date          TEXT NOT NULL,
+	business_date TEXT NOT NULL,
+	approval_date TEXT NOT NULL,
+	trade         TEXT NOT NULL,
+	archived      INTEGER NOT NULL,
+	owner_msp     TEXT NOT NULL,
+	msp_id        TEXT NOT NULL,
+	tx_id         TEXT NOT NULL,
+	block_number  INTEGER NOT NULL,
+	updated_at    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_customers_trade ON customers (trade);
+CREATE TABLE IF NOT EXISTS collaterals (
+	customer      TEXT NOT NULL,
+	collateral_id TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	transfer_from TEXT NOT NULL,
+	transfer_to   TEXT NOT NULL,
+	msp_id        TEXT NOT NULL,
+	tx_id         TEXT NOT NULL,
+	block_number  INTEGER NOT NULL,
+	updated_at    TEXT NOT NULL,
+	PRIMARY KEY (customer, collateral_id)
+);
+CREATE INDEX IF NOT EXISTS idx_collaterals_status ON collaterals (status);
+CREATE TABLE IF NOT EXISTS projects (
+	customer     TEXT NOT NULL,
+	project_id   TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	type         TEXT NOT NULL,
+	trade        TEXT NOT NULL,
+	date         TEXT NOT NULL,
+	approve      INTEGER NOT NULL,
+	part         INTEGER NOT NULL,
+	invest       INTEGER NOT NULL,
+	amount_e4    INTEGER,
+	currency     TEXT NOT NULL,
+	company_type TEXT NOT NULL,
+	msp_id       TEXT NOT NULL,
+	tx_id        TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	updated_at   TEXT NOT NULL,
+	PRIMARY KEY (customer, project_id)
+);
+CREATE INDEX IF NOT EXISTS idx_projects_trade ON projects (trade, date);
+`
+
+// Store 链下查询库
+type Store struct {
+	db     *sql.DB
+	mu     sync.Mutex // 区块按顺序逐个写入
+	target *Target    // 同步的通道和链码
+}
+
+// OpenStore 打开（不存在时创建）链下查询库，只同步 target 通道上 target 链码的写集
+func OpenStore(path string, target *Target) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写连接
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create store schema error, %s", err)
+	}
+	return &Store{db: db, target: target}, nil
+}
+
+// Close 关闭链下查询库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// LastBlock 返回已同步的最后一个区块号，还没有同步过区块时 ok 为 false
+func (s *Store) LastBlock() (number uint64, ok bool, err error) {
+	err = s.db.QueryRow("SELECT last_block FROM sync_state WHERE id = 1").Scan(&number)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return number, true, nil
+}
+
+// Reset 清空链下查询库，之后调用 Sync 从创世区块重新同步
+func (s *Store) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec("DELETE FROM customers; DELETE FROM collaterals; DELETE FROM projects; DELETE FROM sync_state;")
+	return err
+}
+
+// Sync 用账本客户端逐个读取已同步区块之后的全部区块，直到当前区块高度
+func (s *Store) Sync() error {
+	cli, err := ledgerClient(s.target)
+	if err != nil {
+		return err
+	}
+	info, err := cli.QueryInfo(ledger.WithTargetEndpoints(s.target.ledgerPeer()))
+	if err != nil {
+		if isConnectionError(err) {
+			clients.Reset(s.target)
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok, err := s.LastBlock()
+	if err != nil {
+		return err
+	}
+	from := uint64(0)
+	if ok {
+		from = last + 1
+	}
+	for number := from; number < info.BCI.Height; number++ {
+		raw, err := cli.QueryBlock(number, ledger.WithTargetEndpoints(s.target.ledgerPeer()))
+		if err != nil {
+			if isConnectionError(err) {
+				clients.Reset(s.target)
+			}
+			return fmt.Errorf("query block %d error, %s", number, err)
+		}
+		block, err := decodeBlock(raw)
+		if err != nil {
+			return err
+		}
+		if err := s.applyBlock(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleBlock 实现 BlockHandler，随监听服务收到的新区块更新
+// 查询库落后于收到的区块时（如刚重建过），先用账本客户端补齐中间的区块
+func (s *Store) HandleBlock(block *Block) error {
+	last, ok, err := s.LastBlock()
+	if err != nil {
+		return err
+	}
+	if ok && block.Number <= last {
+		return nil
+	}
+	if (!ok && block.Number > 0) || (ok && block.Number > last+1) {
+		// Sync 会同步到最新高度，包含当前区块
+		return s.Sync()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyBlock(block)
+}
+
+// 在一个数据库事务中写入区块中有效交易对 assetscc 的修改，并记录区块号
+func (s *Store) applyBlock(block *Block) error {
+	dbtx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbtx.Rollback()
+
+	for _, tx := range block.Transactions {
+		if !tx.Valid {
+			continue
+		}
+		for _, write := range tx.Writes {
+			if write.Namespace != s.target.Chaincode {
+				continue
+			}
+			if err := applyWrite(dbtx, block.Number, tx, write); err != nil {
+				return fmt.Errorf("apply write of tx %s in block %d error, %s", tx.TxID, block.Number, err)
+			}
+		}
+	}
+
+	if _, err := dbtx.Exec("INSERT OR REPLACE INTO sync_state (id, last_block) VALUES (1, ?)", block.Number); err != nil {
+		return err
+	}
+	return dbtx.Commit()
+}
+
+// 链码数据的 JSON 格式，与链码中的定义一致
+type (
+	ledgerMoney struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	ledgerCustomer struct {
+		ID           string      `json:"id"`
+		Name         string      `json:"name"`
+		Code         string      `json:"code"`
+		Type         string      `json:"type"`
+		Money        ledgerMoney `json:"money"`
+		Person       string      `json:"person"`
+		Date         string      `json:"date"`
+		BusinessDate string      `json:"businessDate"`
+		ApprovalDate string      `json:"approvalDate"`
+		Trade        string      `json:"trade"`
+		Archived     bool        `json:"archived"`
+		OwnerMSP     string      `json:"ownerMsp"`
+		MSPID        string      `json:"mspId"`
+	}
+	ledgerCollateral struct {
+		Customer       string `json:"customer"`
+		CollateralID   string `json:"collateralId"`
+		CollateralName string `json:"collateralName"`
+		Status         string `json:"status"`
+		TransferFrom   string `json:"transferFrom"`
+		TransferTo     string `json:"transferTo"`
+		MSPID          string `json:"mspId"`
+	}
+	ledgerProject struct {
+		Customer           string      `json:"customer"`
+		ProjectName        string      `json:"projectName"`
+		ProjectID          string      `json:"projectId"`
+		ProjectType        string      `json:"projectType"`
+		ProjectTrade       string      `json:"projectTrade"`
+		ProjectDate        string      `json:"projectDate"`
+		ProjectApprove     bool        `json:"projectApprove"`
+		ProjectPart        bool        `json:"projectPart"`
+		ProjectInvest      bool        `json:"projectInvest"`
+		ProjectMoney       ledgerMoney `json:"projectMoney"`
+		ProjectCompanyType string      `json:"projectCompanyType"`
+		MSPID              string      `json:"mspId"`
+	}
+)
+
+// 按组合键的对象类型把一个写入应用到对应的表，索引等其他键忽略
+func applyWrite(dbtx *sql.Tx, blockNumber uint64, tx *Transaction, write *KVWrite) error {
+	objectType, attributes, ok := splitCompositeKey(write.Key)
+	if !ok {
+		return nil
+	}
+	updatedAt := tx.Timestamp.Format(time.RFC3339Nano)
+
+	switch {
+	case objectType == "CustomerInfo" && len(attributes) == 1:
+		if write.IsDelete {
+			_, err := dbtx.Exec("DELETE FROM customers WHERE id = ?", attributes[0])
+			return err
+		}
+		var c ledgerCustomer
+		if err := json.Unmarshal(write.Value, &c); err != nil {
+			// 早期版本的数据格式不同，无法解析时跳过，不影响后续区块
+			fmt.Printf("store: skip customer %s in tx %s, %s\n", attributes[0], tx.TxID, err)
+			return nil
+		}
+		_, err := dbtx.Exec(`INSERT OR REPLACE INTO customers
+			(id, name, code, type, amount_e4, currency, person, date, business_date, approval_date, trade, archived, owner_msp, msp_id, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			attributes[0], c.Name, c.Code, c.Type, amountE4(c.Money.Amount), c.Money.Currency, c.Person,
+			c.Date, c.BusinessDate, c.ApprovalDate, c.Trade, c.Archived, c.OwnerMSP, c.MSPID,
+			tx.TxID, blockNumber, updatedAt)
+		return err
+
+	case objectType == "CollateralInfo" && len(attributes) == 2:
+		if write.IsDelete {
+			_, err := dbtx.Exec("DELETE FROM collaterals WHERE customer = ? AND collateral_id = ?", attributes[0], attributes[1])
+			return err
+		}
+		var c ledgerCollateral
+		if err := json.Unmarshal(write.Value, &c); err != nil {
+			fmt.Printf("store: skip collateral %s/%s in tx %s, %s\n", attributes[0], attributes[1], tx.TxID, err)
+			return nil
+		}
+		_, err := dbtx.Exec(`INSERT OR REPLACE INTO collaterals
+			(customer, collateral_id, name, status, transfer_from, transfer_to, msp_id, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			attributes[0], attributes[1], c.CollateralName, c.Status, c.TransferFrom, c.TransferTo, c.MSPID,
+			tx.TxID, blockNumber, updatedAt)
+		return err
+
+	case objectType == "ProjectInfo" && len(attributes) == 2:
+		if write.IsDelete {
+			_, err := dbtx.Exec("DELETE FROM projects WHERE customer = ? AND project_id = ?", attributes[0], attributes[1])
+			return err
+		}
+		var p ledgerProject
+		if err := json.Unmarshal(write.Value, &p); err != nil {
+			fmt.Printf("store: skip project %s/%s in tx %s, %s\n", attributes[0], attributes[1], tx.TxID, err)
+			return nil
+		}
+		_, err := dbtx.Exec(`INSERT OR REPLACE INTO projects
+			(customer, project_id, name, type, trade, date, approve, part, invest, amount_e4, currency, company_type, msp_id, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			attributes[0], attributes[1], p.ProjectName, p.ProjectType, p.ProjectTrade, p.ProjectDate,
+			p.ProjectApprove, p.ProjectPart, p.ProjectInvest, amountE4(p.ProjectMoney.Amount), p.ProjectMoney.Currency,
+			p.ProjectCompanyType, p.MSPID, tx.TxID, blockNumber, updatedAt)
+		return err
+	}
+	return nil
+}
+
+// 拆分链码的组合键，格式为 \x00对象类型\x00属性1\x00属性2\x00
+func splitCompositeKey(key string) (string, []string, bool) {
+	if len(key) < 2 || key[0] != 0 || key[len(key)-1] != 0 {
+		return "", nil, false
+	}
+	parts := strings.Split(key[1:len(key)-1], "\x00")
+	return parts[0], parts[1:], true
+}
+
+// 把十进制金额转换为以万分之一为单位的整数，格式不正确时返回 nil（存为 NULL）
+func amountE4(amount json.Number) interface{} {
+	s := string(amount)
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" || len(intPart) > 14 || len(fracPart) > 4 {
+		return nil
+	}
+	var v int64
+	for _, c := range intPart + fracPart + strings.Repeat("0", 4-len(fracPart)) {
+		if c < '0' || c > '9' {
+			return nil
+		}
+		v = v*10 + int64(c-'0')
+	}
+	return v
+}
+
+// 把以万分之一为单位的整数格式化为十进制金额
+func formatAmountE4(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := fmt.Sprintf("%s%d.%04d", sign, v/10000, v%10000)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
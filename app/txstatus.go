@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/filter"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// 异步提交和交易状态
+// 异步提交时，交易背书后发送到排序节点即返回，不等待上链；
+// 发送前用事件客户端的 RegisterTxStatusEvent 登记交易，上链后由事件更新状态。
+// 状态只保存在内存中，不在内存中的交易（重启前提交的、同步提交的、超时的）从账本查询。
+
+// 交易状态
+const (
+	TxPending = "pending" //已发送到排序节点，尚未上链
+	TxValid   = "valid"   //已上链，验证通过
+	TxInvalid = "invalid" //已上链，验证未通过，如读写冲突
+)
+
+const (
+	txStatusTimeout   = 5 * time.Minute // 等待交易上链的最长时间，超时后从账本查询
+	txStatusRetention = time.Hour       // 已上链的交易状态在内存中保留的时间
+)
+
+// TxStatus 交易状态
+type TxStatus struct {
+	TxID           string    `json:"txId"`                     //交易id
+	Status         string    `json:"status"`                   //pending、valid、invalid
+	ValidationCode string    `json:"validationCode,omitempty"` //验证结果，如 VALID、MVCC_READ_CONFLICT
+	BlockNumber    uint64    `json:"blockNumber,omitempty"`    //所在的区块
+	Endorsers      []string  `json:"endorsers,omitempty"`      //背书节点，从账本查询时为空
+	SubmittedAt    time.Time `json:"submittedAt,omitempty"`    //异步提交的时间，从账本查询时为空
+	UpdatedAt      time.Time `json:"updatedAt"`                //状态更新的时间
+
+	watching bool //是否还在等待交易事件
+}
+
+// TxTracker 异步提交的交易状态
+type TxTracker struct {
+	mu       sync.Mutex
+	statuses map[string]*TxStatus
+}
+
+// 异步提交的交易状态，所有通道共用
+var txTracker = &TxTracker{statuses: map[string]*TxStatus{}}
+
+// Watch 登记交易并等待交易事件，需要在发送交易之前调用，否则可能错过事件
+func (tr *TxTracker) Watch(t *Target, txID fab.TransactionID, endorsers []string) error {
+	cli, err := clients.Event(t)
+	if err != nil {
+		return err
+	}
+	reg, notifier, err := cli.RegisterTxStatusEvent(string(txID))
+	if err != nil {
+		return fmt.Errorf("register tx status event error, %s", err)
+	}
+
+	now := time.Now()
+	tr.mu.Lock()
+	tr.prune(now)
+	tr.statuses[string(txID)] = &TxStatus{
+		TxID:        string(txID),
+		Status:      TxPending,
+		Endorsers:   endorsers,
+		SubmittedAt: now,
+		UpdatedAt:   now,
+		watching:    true,
+	}
+	tr.mu.Unlock()
+
+	go func() {
+		defer cli.Unregister(reg)
+		select {
+		case evt, ok := <-notifier:
+			if ok {
+				tr.update(evt)
+				return
+			}
+		case <-time.After(txStatusTimeout):
+		}
+		tr.mu.Lock()
+		if s, ok := tr.statuses[string(txID)]; ok {
+			s.watching = false
+		}
+		tr.mu.Unlock()
+	}()
+	return nil
+}
+
+// Forget 交易没有发送成功时删除登记
+func (tr *TxTracker) Forget(txID fab.TransactionID) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	delete(tr.statuses, string(txID))
+}
+
+// Get 返回交易状态的副本
+func (tr *TxTracker) Get(txID string) (TxStatus, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	s, ok := tr.statuses[txID]
+	if !ok {
+		return TxStatus{}, false
+	}
+	return *s, true
+}
+
+func (tr *TxTracker) update(evt *fab.TxStatusEvent) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	s, ok := tr.statuses[evt.TxID]
+	if !ok {
+		return
+	}
+	s.Status = txStatusOf(evt.TxValidationCode)
+	s.ValidationCode = evt.TxValidationCode.String()
+	s.BlockNumber = evt.BlockNumber
+	s.UpdatedAt = time.Now()
+	s.watching = false
+}
+
+// 删除超过保留时间的已上链交易，需要持有锁
+func (tr *TxTracker) prune(now time.Time) {
+	for txID, s := range tr.statuses {
+		if !s.watching && now.Sub(s.UpdatedAt) > txStatusRetention {
+			delete(tr.statuses, txID)
+		}
+	}
+}
+
+func txStatusOf(code peer.TxValidationCode) string {
+	if code == peer.TxValidationCode_VALID {
+		return TxValid
+	}
+	return TxInvalid
+}
+
+// 异步提交的最后一步：登记交易状态后发送到排序节点，不等待上链
+// 前面的背书、背书结果校验与 channel.Execute 相同
+type orderHandler struct {
+	target *Target
+}
+
+func (h *orderHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	txID := requestContext.Response.TransactionID
+	if err := txTracker.Watch(h.target, txID, endorsers(requestContext.Response.Responses)); err != nil {
+		requestContext.Error = err
+		return
+	}
+
+	tx, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest{
+		Proposal:          requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err == nil {
+		_, err = clientContext.Transactor.SendTransaction(tx)
+	}
+	if err != nil {
+		txTracker.Forget(txID)
+		requestContext.Error = fmt.Errorf("send transaction error, %s", err)
+	}
+}
+
+// 异步提交写交易，背书节点的选择和故障转移与 channelExecute 相同
+func channelSubmit(t *Target, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := clients.Channel(t)
+	if err != nil {
+		return channel.Response{}, err
+	}
+
+	handler := invoke.NewSelectAndEndorseHandler(
+		invoke.NewEndorsementValidationHandler(
+			invoke.NewSignatureValidationHandler(&orderHandler{target: t}),
+		),
+	)
+	resp, err := invokeWithFailover(t, filter.EndorsingPeer, func(opts ...channel.RequestOption) (channel.Response, error) {
+		return cli.InvokeHandler(handler, channel.Request{
+			ChaincodeID: t.Chaincode,
+			Fcn:         fcn,
+			Args:        args,
+		}, opts...)
+	})
+	if err != nil {
+		if isConnectionError(err) {
+			clients.Reset(t)
+		}
+		// 背书完成后发送失败时 resp 中带有交易id和背书节点
+		return resp, err
+	}
+	return resp, nil
+}
+
+// 查询交易状态：内存中有且仍在等待事件时直接返回，否则从账本查询，账本中也没有时返回 404
+func queryTransactionStatus(ctx *gin.Context) {
+	txID := ctx.Param("txid")
+	tracked, ok := txTracker.Get(txID)
+	if ok && (tracked.Status != TxPending || tracked.watching) {
+		respondOK(ctx, tracked)
+		return
+	}
+
+	t := target(ctx)
+	cli, err := ledgerClient(t)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	processed, err := cli.QueryTransaction(fab.TransactionID(txID), ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		// 等待超时且账本中还没有的交易仍为 pending
+		if ok {
+			respondOK(ctx, tracked)
+			return
+		}
+		respondLedgerError(ctx, err)
+		return
+	}
+	block, err := cli.QueryBlockByTxID(fab.TransactionID(txID), ledger.WithTargetEndpoints(t.ledgerPeer()))
+	if err != nil {
+		respondLedgerError(ctx, err)
+		return
+	}
+
+	code := peer.TxValidationCode(processed.ValidationCode)
+	s := TxStatus{
+		TxID:           txID,
+		Status:         txStatusOf(code),
+		ValidationCode: code.String(),
+		BlockNumber:    block.Header.Number,
+		Endorsers:      tracked.Endorsers,
+		SubmittedAt:    tracked.SubmittedAt,
+		UpdatedAt:      time.Now(),
+	}
+	respondOK(ctx, s)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 本地钱包
+// 通过 Fabric CA 登记（enroll）的用户证书和私钥由 SDK 保存在 config.yaml 的 credentialStore 和 cryptoStore 中，
+// 钱包只记录网关管理的身份：所属组织、角色和状态，保存在配置的 wallet 文件中。
+// 已登记且未吊销的身份可以在请求头 X-Identity 中使用，以该用户的证书签名交易，账本中可以看到是哪个用户做的修改。
+
+// 身份状态
+const (
+	IdentityRegistered = "registered" //已在 CA 注册，尚未登记证书
+	IdentityEnrolled   = "enrolled"   //已登记证书，可以调用链码
+	IdentityRevoked    = "revoked"    //证书已吊销，不能再使用
+)
+
+// WalletIdentity 钱包中的身份
+type WalletIdentity struct {
+	Name       string     `json:"name"`                 //CA 中的用户名，也是请求头 X-Identity 中的身份名称
+	Org        string     `json:"org"`                  //所属组织，SDK 配置文件 organizations 下的 key
+	Role       string     `json:"role,omitempty"`       //证书中的 role 属性，链码按它控制权限
+	Status     string     `json:"status"`               //registered、enrolled、revoked
+	Serial     string     `json:"serial,omitempty"`     //当前证书的序列号
+	Expires    *time.Time `json:"expires,omitempty"`    //当前证书的到期时间
+	EnrolledAt *time.Time `json:"enrolledAt,omitempty"` //最近一次登记或重新登记的时间
+	UpdatedAt  time.Time  `json:"updatedAt"`            //状态更新的时间
+}
+
+// Wallet 本地钱包的身份索引
+type Wallet struct {
+	path string
+
+	mu         sync.Mutex
+	identities map[string]*WalletIdentity
+}
+
+// 本地钱包，启动时打开
+var wallet *Wallet
+
+// OpenWallet 读取钱包文件，文件不存在时为空钱包
+func OpenWallet(path string) (*Wallet, error) {
+	w := &Wallet{path: path, identities: map[string]*WalletIdentity{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read wallet error, %s", err)
+	}
+	var identities []*WalletIdentity
+	if err := json.Unmarshal(data, &identities); err != nil {
+		return nil, fmt.Errorf("invalid wallet file %s, %s", path, err)
+	}
+	for _, identity := range identities {
+		w.identities[identity.Name] = identity
+	}
+	return w, nil
+}
+
+// Get 返回身份的副本
+func (w *Wallet) Get(name string) (WalletIdentity, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	identity, ok := w.identities[name]
+	if !ok {
+		return WalletIdentity{}, false
+	}
+	return *identity, true
+}
+
+// List 按名称排序返回全部身份
+func (w *Wallet) List() []WalletIdentity {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := make([]WalletIdentity, 0, len(w.identities))
+	for _, identity := range w.identities {
+		list = append(list, *identity)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Put 保存身份并写入钱包文件
+func (w *Wallet) Put(identity WalletIdentity) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	identity.UpdatedAt = time.Now()
+	w.identities[identity.Name] = &identity
+	return w.save()
+}
+
+// Identity 可以调用链码的身份，用于选择调用目标
+func (w *Wallet) Identity(name string) (*IdentityConfig, error) {
+	if w == nil {
+		return nil, fmt.Errorf("identity %q is not configured", name)
+	}
+	identity, ok := w.Get(name)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("identity %q is not configured", name)
+	case identity.Status == IdentityRevoked:
+		return nil, fmt.Errorf("identity %q has been revoked", name)
+	case identity.Status != IdentityEnrolled:
+		return nil, fmt.Errorf("identity %q is not enrolled", name)
+	}
+	return &IdentityConfig{Name: identity.Name, Org: identity.Org, User: identity.Name}, nil
+}
+
+// 写入钱包文件，先写临时文件再重命名，需要持有锁
+func (w *Wallet) save() error {
+	list := make([]*WalletIdentity, 0, len(w.identities))
+	for _, identity := range w.identities {
+		list = append(list, identity)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(w.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := w.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
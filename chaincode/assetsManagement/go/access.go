@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+)
+
+// =======Access control =======================================================================
+// 链码读取提交交易的客户端证书中的 MSP ID 和 role 属性，按 permissions 检查每个函数的调用权限：
+// 写交易只允许信贷员 loanOfficer 提交，并且只能修改本组织的客户及其名下的押品和项目；
+// 早期没有所属组织的客户不能直接修改，需要数据管理员 dataAdmin 先用 claimCustomer 认领到本组织；
+// 历史查询只允许审计员 auditor 调用；任意条件的富查询只允许信贷员和审计员调用；其他查询不限制角色。
+// 未在 permissions 中列出的函数一律拒绝，新增函数时需要同时在这里声明权限。
+// role 属性在 Fabric CA 注册用户时指定并写入证书，如：
+//   fabric-ca-client register --id.name user1 --id.attrs 'role=loanOfficer:ecert'
+// 一个用户有多个角色时用逗号分隔，如 role=loanOfficer,auditor
+// ============================================================================================
+
+// 证书中记录角色的属性名
+const roleAttribute = "role"
+
+// 角色
+const (
+	RoleLoanOfficer = "loanOfficer" //信贷员
+	RoleAuditor     = "auditor"     //审计员
+	RoleDataAdmin   = "dataAdmin"   //数据管理员，认领没有所属组织的客户
+
+	// 不检查角色，任何提交者都可以调用
+	RoleAny = "*"
+)
+
+// 各函数允许调用的角色，未列出的函数不允许调用
+var permissions = map[string][]string{
+	"createCustomer":     {RoleLoanOfficer},
+	"addCustomerInfo":    {RoleLoanOfficer},
+	"updateCustomer":     {RoleLoanOfficer},
+	"archiveCustomer":    {RoleLoanOfficer},
+	"addCollateralInfo":  {RoleLoanOfficer},
+	"addCollateral":      {RoleLoanOfficer},
+	"releaseCollateral":  {RoleLoanOfficer},
+	"transferCollateral": {RoleLoanOfficer},
+	"addProjectInfo":     {RoleLoanOfficer},
+	"claimCustomer":      {RoleDataAdmin},
+
+	"getCustomerInfo":                       {RoleAny},
+	"getCustomerById":                       {RoleAny},
+	"getCustomerByCode":                     {RoleAny},
+	"getCustomersByName":                    {RoleAny},
+	"listProjectsByCustomer":                {RoleAny},
+	"queryProjects":                         {RoleAny},
+	"queryProjectsWithPagination":           {RoleAny},
+	"queryCustomersByCapital":               {RoleAny},
+	"queryCustomersByCapitalWithPagination": {RoleAny},
+
+	// 任意 CouchDB 查询条件可以读取全部数据
+	"queryAssets":               {RoleLoanOfficer, RoleAuditor},
+	"queryAssetsWithPagination": {RoleLoanOfficer, RoleAuditor},
+
+	"getCustomerAsOf":                        {RoleAuditor},
+	"getHistoryProjectInfo":                  {RoleAuditor},
+	"getHistoryProjectInfoWithPagination":    {RoleAuditor},
+	"getHistoryCustomerInfo":                 {RoleAuditor},
+	"getHistoryCustomerInfoWithPagination":   {RoleAuditor},
+	"getHistoryCollateralInfo":               {RoleAuditor},
+	"getHistoryCollateralInfoWithPagination": {RoleAuditor},
+}
+
+// 检查提交者是否有权调用函数
+func checkAccess(stub shim.ChaincodeStubInterface, fn string) error {
+	allowed, ok := permissions[fn]
+	if !ok {
+		return newError(ErrAccessDenied, "", "Access denied: function %s is not permitted", fn)
+	}
+	if len(allowed) == 1 && allowed[0] == RoleAny {
+		return nil
+	}
+	value, found, err := cid.GetAttributeValue(stub, roleAttribute)
+	if err != nil {
+		return fmt.Errorf("get submitter attribute error, %s", err)
+	}
+	if found {
+		for _, role := range strings.Split(value, ",") {
+			for _, allowedRole := range allowed {
+				if strings.TrimSpace(role) == allowedRole {
+					return nil
+				}
+			}
+		}
+	}
+	return newError(ErrAccessDenied, "", "Access denied: %s requires role %s", fn, strings.Join(allowed, " or "))
+}
+
+// 检查提交者是否属于客户的所属组织
+// 没有所属组织的客户为早期数据，认领（claimCustomer）之前任何组织都不能修改
+func checkOwner(stub shim.ChaincodeStubInterface, CustomerInfo *CustomerInfo) error {
+	mspID, err := submitterMSPID(stub)
+	if err != nil {
+		return err
+	}
+	if CustomerInfo.OwnerMSP == "" {
+		return newError(ErrAccessDenied, "id", "Access denied: customer %s has no owner, claim it first", CustomerInfo.ID)
+	}
+	if CustomerInfo.OwnerMSP != mspID {
+		return newError(ErrAccessDenied, "id", "Access denied: customer %s belongs to %s", CustomerInfo.ID, CustomerInfo.OwnerMSP)
+	}
+	return nil
+}
+
+// 获取提交者有权修改的客户，客户不存在、已归档或属于其他组织时报错
+func requireOwnedCustomer(stub shim.ChaincodeStubInterface, CustomerID string) (*CustomerInfo, error) {
+	CustomerInfo, err := requireActiveCustomer(stub, CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwner(stub, CustomerInfo); err != nil {
+		return nil, err
+	}
+	return CustomerInfo, nil
+}
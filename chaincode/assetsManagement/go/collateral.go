@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 押品信息以组合键 CollateralInfo~客户编号~押品编号 逐条存储，新增押品不会覆盖已有押品
+func collateralKey(stub shim.ChaincodeStubInterface, CustomerID, CollateralID string) (string, error) {
+	return stub.CreateCompositeKey("CollateralInfo", []string{CustomerID, CollateralID})
+}
+
+// 读取单条押品信息，押品不存在时返回 nil
+func getCollateral(stub shim.ChaincodeStubInterface, CustomerID, CollateralID string) (*CollateralInfo, error) {
+	key, err := collateralKey(stub, CustomerID, CollateralID)
+	if err != nil {
+		return nil, err
+	}
+	collateralBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("get stateDB error, %s", err)
+	}
+	if collateralBytes == nil {
+		return nil, nil
+	}
+
+	var CollateralInfo CollateralInfo
+	if err := json.Unmarshal(collateralBytes, &CollateralInfo); err != nil {
+		return nil, fmt.Errorf("unmarshal collateral error, %s", err)
+	}
+	return &CollateralInfo, nil
+}
+
+// 写入单条押品信息
+func putCollateral(stub shim.ChaincodeStubInterface, CollateralInfo *CollateralInfo) error {
+	key, err := collateralKey(stub, CollateralInfo.Customer, CollateralInfo.CollateralID)
+	if err != nil {
+		return err
+	}
+	// 记录写入该版本的组织
+	if CollateralInfo.MSPID, err = submitterMSPID(stub); err != nil {
+		return err
+	}
+	JSONasBytes, err := json.Marshal(CollateralInfo)
+	if err != nil {
+		return fmt.Errorf("marshal collateral error, %s", err)
+	}
+	if err := stub.PutState(key, JSONasBytes); err != nil {
+		return fmt.Errorf("put stateDB error, %s", err)
+	}
+	return nil
+}
+
+// 查询客户名下所有押品，包括已解押、已转让的押品
+func listCollaterals(stub shim.ChaincodeStubInterface, CustomerID string) ([]CollateralInfo, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("CollateralInfo", []string{CustomerID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	CollateralInfos := []CollateralInfo{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var CollateralInfo CollateralInfo
+		if err := json.Unmarshal(response.Value, &CollateralInfo); err != nil {
+			return nil, fmt.Errorf("unmarshal collateral error, %s", err)
+		}
+		CollateralInfos = append(CollateralInfos, CollateralInfo)
+	}
+	return CollateralInfos, nil
+}
+
+// 登记单条押品
+// 押品已处于抵押状态时报错；已解押或已转让的押品可以重新登记
+func pledgeCollateral(stub shim.ChaincodeStubInterface, CustomerID, CollateralID, CollateralName string) (*CollateralInfo, error) {
+	if CollateralID == "" {
+		return nil, newError(ErrInvalidArgument, "collateralId", "CollateralID can not be empty.")
+	}
+	existing, err := getCollateral(stub, CustomerID, CollateralID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status == CollateralPledged {
+		return nil, newError(ErrCollateralExists, "collateralId", "Collateral already exist: %s", CollateralID)
+	}
+
+	CollateralInfo := &CollateralInfo{
+		ObjectType:     docTypeCollateral,
+		Customer:       CustomerID,
+		CollateralID:   CollateralID,
+		CollateralName: CollateralName,
+		Status:         CollateralPledged,
+	}
+	if err := putCollateral(stub, CollateralInfo); err != nil {
+		return nil, err
+	}
+	return CollateralInfo, nil
+}
+
+// 添加押品信息，押品编号、押品名称成对出现，支持批量添加
+func (a *AssertsManageCC) addCollateralInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1.检查参数的个数
+	// 判断押品信息是否成对出现
+	if (len(args)-1)%2 != 0 || len(args) == 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting customer id and collateral id/name pairs").Error())
+	}
+
+	// 2.验证参数的正确性
+
+	// 获取客户编号
+	CustomerID := args[0]
+
+	// 3.验证数据是否存在
+	if _, err := requireOwnedCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 批量添加，每条押品单独写入
+	// 同一交易内读不到本交易的写入，需要自行检查重复的押品编号
+	seen := make(map[string]bool)
+	var CollateralInfos []*CollateralInfo
+	for i := 1; i < len(args); i = i + 2 {
+		if seen[args[i]] {
+			return shim.Error(newError(ErrInvalidArgument, "collateralId", "Duplicate collateral id %s", args[i]).Error())
+		}
+		seen[args[i]] = true
+		CollateralInfo, err := pledgeCollateral(stub, CustomerID, args[i], args[i+1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		CollateralInfos = append(CollateralInfos, CollateralInfo)
+	}
+
+	// 4.发出链码事件，单条押品时 key 为押品的键，批量添加时为客户的键
+	var key string
+	var changed map[string]interface{}
+	var err error
+	if len(CollateralInfos) == 1 {
+		if key, err = collateralKey(stub, CustomerID, CollateralInfos[0].CollateralID); err == nil {
+			changed, err = changedFields(nil, CollateralInfos[0])
+		}
+	} else {
+		key, err = customerKey(stub, CustomerID)
+		changed = map[string]interface{}{"collaterals": CollateralInfos}
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setEvent(stub, EventCollateralAdded, key, changed); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 添加单条押品
+// args: 客户编号, 押品编号, 押品名称
+func (a *AssertsManageCC) addCollateral(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 3").Error())
+	}
+	return a.addCollateralInfo(stub, args)
+}
+
+// 解押
+// args: 客户编号, 押品编号
+func (a *AssertsManageCC) releaseCollateral(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 2").Error())
+	}
+	CustomerID, CollateralID := args[0], args[1]
+	if _, err := requireOwnedCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	CollateralInfo, err := getCollateral(stub, CustomerID, CollateralID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if CollateralInfo == nil || CollateralInfo.Status != CollateralPledged {
+		return shim.Error(newError(ErrCollateralNotFound, "collateralId", "Collateral not found: %s", CollateralID).Error())
+	}
+
+	// 只修改状态，不删除记录，保留押品完整的生命周期
+	before := *CollateralInfo
+	CollateralInfo.Status = CollateralReleased
+	if err := putCollateral(stub, CollateralInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setCollateralEvent(stub, EventCollateralReleased, &before, CollateralInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// 押品转让
+// args: 客户编号, 押品编号, 目标客户编号
+func (a *AssertsManageCC) transferCollateral(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 3").Error())
+	}
+	CustomerID, CollateralID, NewCustomerID := args[0], args[1], args[2]
+	if NewCustomerID == "" || NewCustomerID == CustomerID {
+		return shim.Error(newError(ErrInvalidArgument, "newId", "Invalid transfer target.").Error())
+	}
+	if _, err := requireOwnedCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 目标客户可以属于其他组织，只要求存在且未归档
+	if _, err := requireActiveCustomer(stub, NewCustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	Collateral, err := getCollateral(stub, CustomerID, CollateralID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if Collateral == nil || Collateral.Status != CollateralPledged {
+		return shim.Error(newError(ErrCollateralNotFound, "collateralId", "Collateral not found: %s", CollateralID).Error())
+	}
+	if existing, err := getCollateral(stub, NewCustomerID, CollateralID); err != nil {
+		return shim.Error(err.Error())
+	} else if existing != nil && existing.Status == CollateralPledged {
+		return shim.Error(newError(ErrCollateralExists, "collateralId", "Collateral already exist under customer %s: %s", NewCustomerID, CollateralID).Error())
+	}
+
+	// 原客户名下的记录标记为已转让，目标客户名下新建一条抵押记录
+	before := *Collateral
+	Collateral.Status = CollateralTransferred
+	Collateral.TransferTo = NewCustomerID
+	if err := putCollateral(stub, Collateral); err != nil {
+		return shim.Error(err.Error())
+	}
+	target := &CollateralInfo{
+		ObjectType:     docTypeCollateral,
+		Customer:       NewCustomerID,
+		CollateralID:   CollateralID,
+		CollateralName: Collateral.CollateralName,
+		Status:         CollateralPledged,
+		TransferFrom:   CustomerID,
+	}
+	if err := putCollateral(stub, target); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 一个交易只能设置一个事件：key 为原押品的键，changed 的 target 中为目标客户名下新建的记录及其键
+	key, err := collateralKey(stub, CustomerID, CollateralID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	targetKey, err := collateralKey(stub, NewCustomerID, CollateralID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	changed, err := changedFields(&before, Collateral)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	targetChanged, err := changedFields(nil, target)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	changed["target"] = map[string]interface{}{"key": targetKey, "changed": targetChanged}
+	if err := setEvent(stub, EventCollateralTransferred, key, changed); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// 发出押品状态变化的链码事件
+func setCollateralEvent(stub shim.ChaincodeStubInterface, name string, before, after *CollateralInfo) error {
+	key, err := collateralKey(stub, after.Customer, after.CollateralID)
+	if err != nil {
+		return err
+	}
+	changed, err := changedFields(before, after)
+	if err != nil {
+		return err
+	}
+	return setEvent(stub, name, key, changed)
+}
+
+// 获取押品历史数据
+// args: 客户编号[, 押品编号[, 开始时间, 结束时间]]，押品编号为空时返回客户名下全部押品的历史
+func (a *AssertsManageCC) getHistoryCollateralInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1, 2 or 4").Error())
+	}
+	if len(args) == 1 {
+		return historyCollateralInfo(stub, args[0], "", allHistory())
+	}
+	page, err := parseHistoryArgs(args[2:], false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyCollateralInfo(stub, args[0], args[1], page)
+}
+
+// 分页获取押品历史数据
+// args: 客户编号, 押品编号（为空时返回客户名下全部押品的历史）, 每页记录数, 书签[, 开始时间, 结束时间]
+func (a *AssertsManageCC) getHistoryCollateralInfoWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 2 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 4 or 6").Error())
+	}
+	page, err := parseHistoryArgs(args[2:], true)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyCollateralInfo(stub, args[0], args[1], page)
+}
+
+// 按游标获取押品历史数据
+func historyCollateralInfo(stub shim.ChaincodeStubInterface, CustomerID, CollateralID string, page *historyPage) pb.Response {
+	if _, err := requireCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var CollateralIDs []string
+	if CollateralID != "" {
+		CollateralIDs = append(CollateralIDs, CollateralID)
+	} else {
+		CollateralInfos, err := listCollaterals(stub, CustomerID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		for _, CollateralInfo := range CollateralInfos {
+			CollateralIDs = append(CollateralIDs, CollateralInfo.CollateralID)
+		}
+	}
+
+	HistoryCollateralInfos := []HistoryCollateralInfo{}
+	for _, CollateralID := range CollateralIDs {
+		key, err := collateralKey(stub, CustomerID, CollateralID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		histories, err := getHistoryForCollateral(stub, key, page)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		HistoryCollateralInfos = append(HistoryCollateralInfos, histories...)
+		if page.more {
+			break
+		}
+	}
+	jsonsAsBytes, err := page.result(HistoryCollateralInfos)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonsAsBytes)
+}
+
+// 获取单个押品的历史数据
+func getHistoryForCollateral(stub shim.ChaincodeStubInterface, key string, page *historyPage) ([]HistoryCollateralInfo, error) {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	HistoryCollateralInfos := []HistoryCollateralInfo{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		txTime := historyTime(response)
+		if !page.inRange(txTime) {
+			continue
+		}
+		if !page.take() {
+			if page.more {
+				break
+			}
+			continue
+		}
+		var HistoryCollateralInfo HistoryCollateralInfo
+		HistoryCollateralInfo.TxID = response.TxId
+		HistoryCollateralInfo.Time = txTime.Format(historyTimeLayout)
+		HistoryCollateralInfo.IsDelete = response.IsDelete
+		// 删除操作没有数据，collateralInfo 为 null
+		if !response.IsDelete {
+			HistoryCollateralInfo.CollateralInfo = new(CollateralInfo)
+			if err := json.Unmarshal(response.Value, HistoryCollateralInfo.CollateralInfo); err != nil {
+				return nil, fmt.Errorf("unmarshal collateral history error, tx %s, %s", response.TxId, err)
+			}
+			HistoryCollateralInfo.MSPID = HistoryCollateralInfo.CollateralInfo.MSPID
+		}
+		HistoryCollateralInfos = append(HistoryCollateralInfos, HistoryCollateralInfo)
+	}
+	return HistoryCollateralInfos, nil
+}
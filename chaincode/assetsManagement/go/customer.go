@@ -0,0 +1,629 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 客户索引
+// 索引是普通的 KV 记录，键为组合键，值为空字符，用于按统一社会信用代码、客户名称查找客户编号
+const (
+	codeIndex = "code~id" //统一社会信用代码唯一
+	nameIndex = "name~id" //客户名称可以重复
+)
+
+// 解析客户信息参数
+// args: 客户名称, 客户编号, 统一社会信用代码, 类型, 注册资本, 法人代表, 成立日期, 营业期限, 核准日期, 所属行业
+// 注册资本格式为 "金额[ 币种]"，日期格式为 YYYY-MM-DD
+func parseCustomerInfo(args []string) (CustomerInfo, error) {
+	var CustomerInfo CustomerInfo
+	var err error
+	CustomerInfo.ObjectType = docTypeCustomer
+
+	// 1.检查参数的个数
+	if len(args) != 10 {
+		return CustomerInfo, newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 10")
+	}
+
+	// 2.验证参数的正确性
+	CustomerInfo.Name = args[0]
+	if CustomerInfo.Name == "" {
+		return CustomerInfo, newError(ErrInvalidArgument, "name", "CustomerName can not be empty.")
+	}
+	CustomerInfo.ID = args[1]
+	if CustomerInfo.ID == "" {
+		return CustomerInfo, newError(ErrInvalidArgument, "id", "CustomerID can not be empty.")
+	}
+	CustomerInfo.Code = strings.ToUpper(args[2])
+	if err = validateCreditCode("code", CustomerInfo.Code); err != nil {
+		return CustomerInfo, err
+	}
+	CustomerInfo.Type = args[3]
+	if CustomerInfo.Money, err = parseMoney("money", args[4]); err != nil {
+		return CustomerInfo, err
+	}
+	CustomerInfo.Person = args[5]
+	if CustomerInfo.Date, err = parseDate("date", args[6]); err != nil {
+		return CustomerInfo, err
+	}
+	if CustomerInfo.BusinessDate, err = parseDate("businessDate", args[7]); err != nil {
+		return CustomerInfo, err
+	}
+	// ISO-8601 日期可以直接按字符串比较先后
+	if CustomerInfo.BusinessDate < CustomerInfo.Date {
+		return CustomerInfo, newError(ErrInvalidDateRange, "businessDate", "businessDate can not be earlier than date")
+	}
+	if CustomerInfo.ApprovalDate, err = parseDate("approvalDate", args[8]); err != nil {
+		return CustomerInfo, err
+	}
+	CustomerInfo.Trade = args[9]
+
+	return CustomerInfo, nil
+}
+
+// 客户登记键，客户信息以组合键 CustomerInfo~客户编号 存储
+// 判断客户是否存在只以此键为准
+func customerKey(stub shim.ChaincodeStubInterface, CustomerID string) (string, error) {
+	return stub.CreateCompositeKey("CustomerInfo", []string{CustomerID})
+}
+
+// 读取客户信息，客户不存在时返回 nil
+func getCustomer(stub shim.ChaincodeStubInterface, CustomerID string) (*CustomerInfo, error) {
+	key, err := customerKey(stub, CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	customerBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("get stateDB error, %s", err)
+	}
+	if customerBytes == nil {
+		return nil, nil
+	}
+
+	var CustomerInfo CustomerInfo
+	if err := json.Unmarshal(customerBytes, &CustomerInfo); err != nil {
+		return nil, fmt.Errorf("unmarshal customer error, %s", err)
+	}
+	return &CustomerInfo, nil
+}
+
+// 客户存在性检查，所有读写客户名下数据的操作都先经过此检查
+// 客户不存在时返回 CUSTOMER_NOT_FOUND 错误
+func requireCustomer(stub shim.ChaincodeStubInterface, CustomerID string) (*CustomerInfo, error) {
+	if CustomerID == "" {
+		return nil, newError(ErrInvalidArgument, "id", "CustomerID can not be empty.")
+	}
+	CustomerInfo, err := getCustomer(stub, CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if CustomerInfo == nil {
+		return nil, newError(ErrCustomerNotFound, "id", "Customer not found: %s", CustomerID)
+	}
+	return CustomerInfo, nil
+}
+
+// 写操作使用的客户检查，已归档的客户不允许再修改或挂接押品、项目
+func requireActiveCustomer(stub shim.ChaincodeStubInterface, CustomerID string) (*CustomerInfo, error) {
+	CustomerInfo, err := requireCustomer(stub, CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if CustomerInfo.Archived {
+		return nil, newError(ErrCustomerArchived, "id", "Customer has been archived: %s", CustomerID)
+	}
+	return CustomerInfo, nil
+}
+
+// 写入客户信息
+func putCustomer(stub shim.ChaincodeStubInterface, CustomerInfo *CustomerInfo) error {
+	key, err := customerKey(stub, CustomerInfo.ID)
+	if err != nil {
+		return err
+	}
+	// 记录写入该版本的组织
+	if CustomerInfo.MSPID, err = submitterMSPID(stub); err != nil {
+		return err
+	}
+	// 新建的客户归属于创建者的组织
+	if CustomerInfo.OwnerMSP == "" {
+		CustomerInfo.OwnerMSP = CustomerInfo.MSPID
+	}
+	// 序列化对象 CustomerInfo
+	JSONasBytes, err := json.Marshal(CustomerInfo)
+	if err != nil {
+		return fmt.Errorf("marshal error, %s", err)
+	}
+	// 用 stub.PutState 写入 stateDB（KV类型数据库）
+	// PutState 方法， 如果数据不存在，就新增；如果数据存在，就修改。
+	if err := stub.PutState(key, JSONasBytes); err != nil {
+		return fmt.Errorf("put stateDB error, %s", err)
+	}
+	return nil
+}
+
+// 写入索引记录，只需要键，值写空字符（写入 nil 相当于删除）
+func putIndex(stub shim.ChaincodeStubInterface, indexName string, attributes []string) error {
+	indexKey, err := stub.CreateCompositeKey(indexName, attributes)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(indexKey, []byte{0x00})
+}
+
+// 删除索引记录
+func delIndex(stub shim.ChaincodeStubInterface, indexName string, attributes []string) error {
+	indexKey, err := stub.CreateCompositeKey(indexName, attributes)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(indexKey)
+}
+
+// 通过索引查找客户编号
+func lookupIndex(stub shim.ChaincodeStubInterface, indexName, value string) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(indexName, []string{value})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var CustomerIDs []string
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		// 从组合键中取出客户编号
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		CustomerIDs = append(CustomerIDs, compositeKeyParts[1])
+	}
+	return CustomerIDs, nil
+}
+
+// 检查统一社会信用代码是否已被其他客户使用
+func checkCodeUnique(stub shim.ChaincodeStubInterface, Code, CustomerID string) error {
+	CustomerIDs, err := lookupIndex(stub, codeIndex, Code)
+	if err != nil {
+		return err
+	}
+	for _, id := range CustomerIDs {
+		if id != CustomerID {
+			return newError(ErrDuplicateCreditCode, "code", "Code %s is already used by customer %s", Code, id)
+		}
+	}
+	return nil
+}
+
+// 新建客户信息，客户已存在时报错，避免重复提交覆盖已有客户
+func (a *AssertsManageCC) createCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1.检查并解析参数
+	CustomerInfo, err := parseCustomerInfo(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 2.验证数据是否存在，已归档的客户同样视为存在
+	existing, err := getCustomer(stub, CustomerInfo.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return shim.Error(newError(ErrCustomerExists, "id", "Customer already exist: %s", CustomerInfo.ID).Error())
+	}
+	if err := checkCodeUnique(stub, CustomerInfo.Code, CustomerInfo.ID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3.状态写入
+	if err := putCustomer(stub, &CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4.维护索引
+	if err := putIndex(stub, codeIndex, []string{CustomerInfo.Code, CustomerInfo.ID}); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putIndex(stub, nameIndex, []string{CustomerInfo.Name, CustomerInfo.ID}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 5.发出链码事件
+	if err := setCustomerEvent(stub, EventCustomerCreated, nil, &CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 修改客户信息，客户不存在或已归档时报错
+func (a *AssertsManageCC) updateCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	CustomerInfo, err := parseCustomerInfo(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	existing, err := requireOwnedCustomer(stub, CustomerInfo.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	CustomerInfo.OwnerMSP = existing.OwnerMSP
+	if err := checkCodeUnique(stub, CustomerInfo.Code, CustomerInfo.ID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := putCustomer(stub, &CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 统一社会信用代码、客户名称变化时同步更新索引
+	if existing.Code != CustomerInfo.Code {
+		if err := delIndex(stub, codeIndex, []string{existing.Code, existing.ID}); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := putIndex(stub, codeIndex, []string{CustomerInfo.Code, CustomerInfo.ID}); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if existing.Name != CustomerInfo.Name {
+		if err := delIndex(stub, nameIndex, []string{existing.Name, existing.ID}); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := putIndex(stub, nameIndex, []string{CustomerInfo.Name, CustomerInfo.ID}); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if err := setCustomerEvent(stub, EventCustomerUpdated, existing, &CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// 认领早期没有所属组织的客户，认领后归属于提交者的组织
+// args: 客户编号
+func (a *AssertsManageCC) claimCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+	CustomerInfo, err := requireActiveCustomer(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if CustomerInfo.OwnerMSP != "" {
+		return shim.Error(newError(ErrAccessDenied, "id", "Access denied: customer %s already belongs to %s", CustomerInfo.ID, CustomerInfo.OwnerMSP).Error())
+	}
+
+	// OwnerMSP 为空时 putCustomer 写入提交者的组织
+	before := *CustomerInfo
+	if err := putCustomer(stub, CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setCustomerEvent(stub, EventCustomerUpdated, &before, CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// 归档客户（软删除）
+// 不调用 DelState，而是打上归档标记后重新写入，GetHistoryForKey 的历史记录保持完整
+// 索引保留，已归档客户的统一社会信用代码不能被新客户使用
+func (a *AssertsManageCC) archiveCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+	CustomerID := args[0]
+
+	CustomerInfo, err := requireOwnedCustomer(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	before := *CustomerInfo
+	CustomerInfo.Archived = true
+	if err := putCustomer(stub, CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setCustomerEvent(stub, EventCustomerArchived, &before, CustomerInfo); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// 发出客户信息变化的链码事件，before 为 nil 表示新建客户
+func setCustomerEvent(stub shim.ChaincodeStubInterface, name string, before, after *CustomerInfo) error {
+	key, err := customerKey(stub, after.ID)
+	if err != nil {
+		return err
+	}
+	var changed map[string]interface{}
+	if before == nil {
+		changed, err = changedFields(nil, after)
+	} else {
+		changed, err = changedFields(before, after)
+	}
+	if err != nil {
+		return err
+	}
+	return setEvent(stub, name, key, changed)
+}
+
+// 获取客户信息
+func (a *AssertsManageCC) getCustomerInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1.检查参数的个数
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+
+	// 2.验证参数的正确性
+	CustomerID := args[0]
+
+	// 3.验证数据是否存在
+	CustomerInfo, err := requireCustomer(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	CollateralInfos, err := listCollaterals(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	ProjectInfos, err := listProjects(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var Customer Customer
+	Customer.ID = CustomerInfo.ID
+	Customer.Name = CustomerInfo.Name
+	Customer.CustomerInfo = *CustomerInfo
+	Customer.CollateralInfo = CollateralInfos
+	Customer.ProjectInfos = ProjectInfos
+
+	CustomerAsBytes, err := json.Marshal(Customer)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal project error, %s", err))
+		// return shim.Error(err.Error())
+	}
+
+	return shim.Success(CustomerAsBytes)
+}
+
+// 获取客户在指定时间点的全部信息（客户信息、押品、项目），用于按审计日回溯
+// args: 客户编号, 时间点（RFC3339 时间或 ISO-8601 日期，日期表示当天结束时）
+// 逐个遍历客户、押品和项目的历史记录，取该时间点之前最后写入的版本，当时尚不存在的押品和项目不返回
+func (a *AssertsManageCC) getCustomerAsOf(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 2").Error())
+	}
+	CustomerID := args[0]
+	// 转换为不含的上界，与历史查询的结束时间一致
+	_, asOf, err := parseHistoryTimeRange("", args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	key, err := customerKey(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	CustomerBytes, err := getStateAsOf(stub, key, asOf)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if CustomerBytes == nil {
+		return shim.Error(newError(ErrCustomerNotFound, "id", "Customer not found as of %s: %s", args[1], CustomerID).Error())
+	}
+	var CustomerInfo CustomerInfo
+	if err := json.Unmarshal(CustomerBytes, &CustomerInfo); err != nil {
+		return shim.Error(fmt.Sprintf("unmarshal customer error, %s", err))
+	}
+
+	// 押品和项目都不会被删除，当前客户名下的键包含了历史上出现过的全部键
+	CollateralInfos := []CollateralInfo{}
+	Collaterals, err := listCollaterals(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, Collateral := range Collaterals {
+		key, err := collateralKey(stub, CustomerID, Collateral.CollateralID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		CollateralBytes, err := getStateAsOf(stub, key, asOf)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if CollateralBytes == nil {
+			continue
+		}
+		var CollateralInfo CollateralInfo
+		if err := json.Unmarshal(CollateralBytes, &CollateralInfo); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal collateral error, %s", err))
+		}
+		CollateralInfos = append(CollateralInfos, CollateralInfo)
+	}
+
+	ProjectInfos := []ProjectInfo{}
+	Projects, err := listProjects(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, Project := range Projects {
+		key, err := projectKey(stub, CustomerID, Project.ProjectID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		ProjectBytes, err := getStateAsOf(stub, key, asOf)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if ProjectBytes == nil {
+			continue
+		}
+		var ProjectInfo ProjectInfo
+		if err := json.Unmarshal(ProjectBytes, &ProjectInfo); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal project error, %s", err))
+		}
+		ProjectInfos = append(ProjectInfos, ProjectInfo)
+	}
+
+	var Customer Customer
+	Customer.ID = CustomerInfo.ID
+	Customer.Name = CustomerInfo.Name
+	Customer.CustomerInfo = CustomerInfo
+	Customer.CollateralInfo = CollateralInfos
+	Customer.ProjectInfos = ProjectInfos
+
+	CustomerAsBytes, err := json.Marshal(Customer)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal customer error, %s", err))
+	}
+	return shim.Success(CustomerAsBytes)
+}
+
+// 按客户编号查询客户信息
+func (a *AssertsManageCC) getCustomerById(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+
+	CustomerInfo, err := requireCustomer(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	CustomerAsBytes, err := json.Marshal(CustomerInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(CustomerAsBytes)
+}
+
+// 按统一社会信用代码查询客户信息
+func (a *AssertsManageCC) getCustomerByCode(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+	Code := strings.ToUpper(args[0])
+
+	CustomerIDs, err := lookupIndex(stub, codeIndex, Code)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(CustomerIDs) == 0 {
+		return shim.Error(newError(ErrCustomerNotFound, "code", "Customer not found: %s", Code).Error())
+	}
+	return a.getCustomerById(stub, CustomerIDs[:1])
+}
+
+// 按客户名称查询客户信息，同名客户全部返回
+func (a *AssertsManageCC) getCustomersByName(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+
+	CustomerIDs, err := lookupIndex(stub, nameIndex, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	CustomerInfos := []CustomerInfo{}
+	for _, CustomerID := range CustomerIDs {
+		CustomerInfo, err := requireCustomer(stub, CustomerID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		CustomerInfos = append(CustomerInfos, *CustomerInfo)
+	}
+	jsonsAsBytes, err := json.Marshal(CustomerInfos)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonsAsBytes)
+}
+
+// 获取客户历史数据
+// args: 客户编号[, 开始时间, 结束时间]
+func (a *AssertsManageCC) getHistoryCustomerInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1 or 3").Error())
+	}
+	page, err := parseHistoryArgs(args[1:], false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyCustomerInfo(stub, args[0], page)
+}
+
+// 分页获取客户历史数据
+// args: 客户编号, 每页记录数, 书签[, 开始时间, 结束时间]
+func (a *AssertsManageCC) getHistoryCustomerInfoWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 3 or 5").Error())
+	}
+	page, err := parseHistoryArgs(args[1:], true)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyCustomerInfo(stub, args[0], page)
+}
+
+// 按游标获取客户历史数据
+func historyCustomerInfo(stub shim.ChaincodeStubInterface, CustomerID string, page *historyPage) pb.Response {
+	if _, err := requireCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+	key, err := customerKey(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	HistoryCustomerInfos := []HistoryCustomerInfo{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		txTime := historyTime(response)
+		if !page.inRange(txTime) {
+			continue
+		}
+		if !page.take() {
+			if page.more {
+				break
+			}
+			continue
+		}
+		var HistoryCustomerInfo HistoryCustomerInfo
+		HistoryCustomerInfo.TxID = response.TxId
+		HistoryCustomerInfo.Time = txTime.Format(historyTimeLayout)
+		HistoryCustomerInfo.IsDelete = response.IsDelete
+		// 删除操作没有数据，customerInfo 为 null
+		if !response.IsDelete {
+			HistoryCustomerInfo.CustomerInfo = new(CustomerInfo)
+			if err := json.Unmarshal(response.Value, HistoryCustomerInfo.CustomerInfo); err != nil {
+				return shim.Error(fmt.Sprintf("unmarshal customer history error, tx %s, %s", response.TxId, err))
+			}
+			HistoryCustomerInfo.MSPID = HistoryCustomerInfo.CustomerInfo.MSPID
+		}
+		HistoryCustomerInfos = append(HistoryCustomerInfos, HistoryCustomerInfo)
+	}
+	jsonsAsBytes, err := page.result(HistoryCustomerInfos)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonsAsBytes)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorCode 结构化错误码
+type ErrorCode string
+
+// 错误码目录，REST 网关根据错误码返回对应的 HTTP 状态码
+// 网关 app/errors.go 中有相同的定义，新增或修改错误码时两边需要同步
+const (
+	ErrInvalidArgument   ErrorCode = "INVALID_ARGUMENT"    //参数个数或内容不正确
+	ErrInvalidAmount     ErrorCode = "INVALID_AMOUNT"      //金额格式错误
+	ErrInvalidCurrency   ErrorCode = "INVALID_CURRENCY"    //币种格式错误
+	ErrInvalidDate       ErrorCode = "INVALID_DATE"        //日期格式错误
+	ErrInvalidDateRange  ErrorCode = "INVALID_DATE_RANGE"  //日期先后顺序错误
+	ErrInvalidBoolean    ErrorCode = "INVALID_BOOLEAN"     //是否类字段取值错误
+	ErrInvalidCreditCode ErrorCode = "INVALID_CREDIT_CODE" //统一社会信用代码错误
+
+	ErrCustomerNotFound ErrorCode = "CUSTOMER_NOT_FOUND" //客户不存在
+	ErrCustomerExists   ErrorCode = "CUSTOMER_EXISTS"    //客户已存在
+	ErrCustomerArchived ErrorCode = "CUSTOMER_ARCHIVED"  //客户已归档
+
+	ErrCollateralNotFound ErrorCode = "COLLATERAL_NOT_FOUND" //押品不存在或不处于抵押状态
+	ErrCollateralExists   ErrorCode = "COLLATERAL_EXISTS"    //押品已处于抵押状态
+	ErrProjectExists      ErrorCode = "PROJECT_EXISTS"       //项目已存在
+
+	ErrDuplicateCreditCode ErrorCode = "DUPLICATE_CREDIT_CODE" //统一社会信用代码已被其他客户使用
+
+	ErrAccessDenied ErrorCode = "ACCESS_DENIED" //没有调用权限
+)
+
+// ccError 链码返回的结构化错误，序列化为 JSON 后作为 shim.Error 的 message
+type ccError struct {
+	Code    ErrorCode `json:"code"`            //错误码
+	Field   string    `json:"field,omitempty"` //出错的字段
+	Message string    `json:"message"`         //错误描述
+}
+
+func (e *ccError) Error() string {
+	JSONasBytes, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(JSONasBytes)
+}
+
+// 构造结构化错误
+func newError(code ErrorCode, field, format string, a ...interface{}) error {
+	return &ccError{Code: code, Field: field, Message: fmt.Sprintf(format, a...)}
+}
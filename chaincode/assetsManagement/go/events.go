@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// =======Chaincode events ======================================================================
+// 每个写交易提交后发出一个链码事件，事件名称见下方常量，内容为 AssetEvent 的 JSON。
+// 一个交易只能设置一个事件（重复调用 SetEvent 以最后一次为准），批量写入时 key 为客户的键，
+// changed 中列出本次写入的全部记录；押品转让时 key 为原押品的键，changed 的 target 为目标客户名下
+// 新建的押品记录（key 为其键，changed 为其全部字段）。
+// ============================================================================================
+
+// 链码事件名称
+const (
+	EventCustomerCreated       = "CustomerCreated"       //新建客户
+	EventCustomerUpdated       = "CustomerUpdated"       //修改客户信息
+	EventCustomerArchived      = "CustomerArchived"      //归档客户
+	EventCollateralAdded       = "CollateralAdded"       //添加押品
+	EventCollateralReleased    = "CollateralReleased"    //押品解押
+	EventCollateralTransferred = "CollateralTransferred" //押品转让
+	EventProjectAdded          = "ProjectAdded"          //添加项目（审批未通过）
+	EventProjectApproved       = "ProjectApproved"       //添加审批通过的项目
+)
+
+// AssetEvent 链码事件内容
+type AssetEvent struct {
+	Key     string                 `json:"key"`     //变化记录的键
+	TxID    string                 `json:"txId"`    //交易id
+	Changed map[string]interface{} `json:"changed"` //变化的字段及新值
+}
+
+// 设置本交易的链码事件
+func setEvent(stub shim.ChaincodeStubInterface, name, key string, changed map[string]interface{}) error {
+	payload, err := json.Marshal(AssetEvent{
+		Key:     key,
+		TxID:    stub.GetTxID(),
+		Changed: changed,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal event error, %s", err)
+	}
+	if err := stub.SetEvent(name, payload); err != nil {
+		return fmt.Errorf("set event error, %s", err)
+	}
+	return nil
+}
+
+// 比较记录前后两个版本，返回发生变化的字段（按 JSON 字段名），before 为 nil 时返回 after 的全部字段
+func changedFields(before, after interface{}) (map[string]interface{}, error) {
+	afterFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return afterFields, nil
+	}
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]interface{}{}
+	for field, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[field], value) {
+			changed[field] = value
+		}
+	}
+	return changed, nil
+}
+
+// 把记录转换为 JSON 字段名到值的映射
+func toFields(record interface{}) (map[string]interface{}, error) {
+	JSONasBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event record error, %s", err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(JSONasBytes, &fields); err != nil {
+		return nil, fmt.Errorf("unmarshal event record error, %s", err)
+	}
+	return fields, nil
+}
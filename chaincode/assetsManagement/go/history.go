@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// =======History ==============================================================================
+// 历史记录的时间统一为 UTC 的 RFC3339 格式（保留纳秒），被删除的版本 isDelete 为 true、记录内容为 null。
+// GetHistoryForKey 不返回交易提交者，写入时把提交者所属组织的 MSP ID 记在数据的 mspId 字段中，
+// 历史记录中的 mspId 即为写入该版本的组织。
+// 历史查询可以在参数末尾追加起止时间 from, to 过滤，取值为 RFC3339 时间或 ISO-8601 日期，
+// 为空时不限制；日期形式的 to 包含当天。
+// ============================================================================================
+
+// 历史记录时间格式
+const historyTimeLayout = time.RFC3339Nano
+
+// 获取提交交易的客户端所属组织的 MSP ID
+func submitterMSPID(stub shim.ChaincodeStubInterface) (string, error) {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", fmt.Errorf("get submitter MSP ID error, %s", err)
+	}
+	return mspID, nil
+}
+
+// 历史记录对应交易的 UTC 时间
+func historyTime(response *queryresult.KeyModification) time.Time {
+	if response.Timestamp == nil {
+		return time.Time{}
+	}
+	return time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).UTC()
+}
+
+// 解析历史查询的起止时间，返回 [from, to) 区间，参数为空时对应的边界为零值
+func parseHistoryTimeRange(fromArg, toArg string) (from, to time.Time, err error) {
+	if fromArg != "" {
+		if from, _, err = parseHistoryTime("from", fromArg); err != nil {
+			return
+		}
+	}
+	if toArg != "" {
+		var isDate bool
+		if to, isDate, err = parseHistoryTime("to", toArg); err != nil {
+			return
+		}
+		// 结束时间包含在区间内，日期包含当天
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			to = to.Add(time.Nanosecond)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		err = newError(ErrInvalidDateRange, "to", "to must not be earlier than from")
+	}
+	return
+}
+
+// 解析单个时间参数，支持 RFC3339 时间和 ISO-8601 日期
+func parseHistoryTime(field, value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), false, nil
+	}
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, newError(ErrInvalidDate, field, "%s must be an RFC3339 time or an ISO-8601 date (YYYY-MM-DD): %s", field, value)
+}
+
+// 解析历史查询末尾的分页和时间参数
+// 分页查询时为 每页记录数, 书签[, 开始时间, 结束时间]，不分页时为 [开始时间, 结束时间]
+func parseHistoryArgs(args []string, paginated bool) (*historyPage, error) {
+	page := allHistory()
+	if paginated {
+		if len(args) < 2 {
+			return nil, newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting pageSize and bookmark")
+		}
+		var err error
+		if page, err = parseHistoryPage(args[0], args[1]); err != nil {
+			return nil, err
+		}
+		args = args[2:]
+	}
+	switch len(args) {
+	case 0:
+		return page, nil
+	case 2:
+		return page.withTimeRange(args[0], args[1])
+	}
+	return nil, newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting both from and to")
+}
+
+// 获取键在指定时间之前（不含）最后写入的值，该时间之前不存在或已被删除时返回 nil
+func getStateAsOf(stub shim.ChaincodeStubInterface, key string, asOf time.Time) ([]byte, error) {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var value []byte
+	var latest time.Time
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		txTime := historyTime(response)
+		if !txTime.Before(asOf) || txTime.Before(latest) {
+			continue
+		}
+		latest = txTime
+		value = nil
+		if !response.IsDelete {
+			value = response.Value
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// =======Pagination ===========================================================================
+// 分页查询返回 {"records":[...], "fetchedRecordsCount":n, "bookmark":"..."}，
+// 取下一页时把上一页返回的 bookmark 原样传回，bookmark 为空表示已经没有更多数据。
+// 富查询使用 GetQueryResultWithPagination，书签由状态数据库生成；
+// GetHistoryForKey 不支持分页，历史查询的书签为已经返回的记录数。
+// 分页查询只能在查询（只读）交易中使用。
+// ============================================================================================
+
+// 单页最多返回的记录数
+const maxPageSize = 1000
+
+// PaginatedResult 分页查询结果
+type PaginatedResult struct {
+	Records             interface{} `json:"records"`             //本页记录
+	FetchedRecordsCount int32       `json:"fetchedRecordsCount"` //本页记录数
+	Bookmark            string      `json:"bookmark"`            //下一页书签
+}
+
+// 解析每页记录数
+func parsePageSize(value string) (int32, error) {
+	pageSize, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || pageSize <= 0 || pageSize > maxPageSize {
+		return 0, newError(ErrInvalidArgument, "pageSize", "pageSize must be an integer between 1 and %d: %s", maxPageSize, value)
+	}
+	return int32(pageSize), nil
+}
+
+// 执行分页富查询
+func getQueryResultForQueryStringWithPagination(stub shim.ChaincodeStubInterface, queryString string, pageSize int32, bookmark string) ([]byte, error) {
+	resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(PaginatedResult{
+		Records:             json.RawMessage(records),
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	})
+}
+
+// historyPage 历史查询的分页游标
+// 按顺序遍历各个键的历史记录，只统计交易时间在 [from, to) 内的记录，
+// 跳过 offset 条之后最多收集 limit 条，limit 为 0 时不分页
+type historyPage struct {
+	offset  int
+	limit   int
+	from    time.Time //开始时间，零值表示不限制
+	to      time.Time //结束时间（不含），零值表示不限制
+	scanned int       //已遍历的记录数
+	fetched int       //本页已收集的记录数
+	more    bool      //本页之后是否还有记录
+}
+
+// 不分页的游标，返回全部历史记录
+func allHistory() *historyPage {
+	return &historyPage{}
+}
+
+// 解析历史查询的每页记录数和书签
+func parseHistoryPage(pageSizeArg, bookmark string) (*historyPage, error) {
+	pageSize, err := parsePageSize(pageSizeArg)
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	if bookmark != "" {
+		offset, err = strconv.Atoi(bookmark)
+		if err != nil || offset < 0 {
+			return nil, newError(ErrInvalidArgument, "bookmark", "invalid bookmark: %s", bookmark)
+		}
+	}
+	return &historyPage{offset: offset, limit: int(pageSize)}, nil
+}
+
+// 设置起止时间过滤
+func (p *historyPage) withTimeRange(fromArg, toArg string) (*historyPage, error) {
+	from, to, err := parseHistoryTimeRange(fromArg, toArg)
+	if err != nil {
+		return nil, err
+	}
+	p.from, p.to = from, to
+	return p, nil
+}
+
+// 交易时间是否在查询区间内
+func (p *historyPage) inRange(t time.Time) bool {
+	if !p.from.IsZero() && t.Before(p.from) {
+		return false
+	}
+	if !p.to.IsZero() && !t.Before(p.to) {
+		return false
+	}
+	return true
+}
+
+// 本页是否已经收集满，收满后再遇到记录时标记还有下一页
+func (p *historyPage) full() bool {
+	return p.limit > 0 && p.fetched >= p.limit
+}
+
+// 遍历到一条时间区间内的记录，返回这条记录是否属于本页
+func (p *historyPage) take() bool {
+	if p.full() {
+		p.more = true
+		return false
+	}
+	p.scanned++
+	if p.scanned <= p.offset {
+		return false
+	}
+	p.fetched++
+	return true
+}
+
+// 下一页书签，没有更多记录时为空
+func (p *historyPage) bookmark() string {
+	if !p.more {
+		return ""
+	}
+	return strconv.Itoa(p.scanned)
+}
+
+// 按游标生成返回结果，不分页时直接返回记录数组
+func (p *historyPage) result(records interface{}) ([]byte, error) {
+	if p.limit == 0 {
+		return json.Marshal(records)
+	}
+	return json.Marshal(PaginatedResult{
+		Records:             records,
+		FetchedRecordsCount: int32(p.fetched),
+		Bookmark:            p.bookmark(),
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 项目信息以组合键 ProjectInfo~客户编号~项目编号 存储，同一客户可登记多个项目
+func projectKey(stub shim.ChaincodeStubInterface, CustomerID, ProjectID string) (string, error) {
+	return stub.CreateCompositeKey("ProjectInfo", []string{CustomerID, ProjectID})
+}
+
+// 查询客户名下所有项目
+func listProjects(stub shim.ChaincodeStubInterface, CustomerID string) ([]ProjectInfo, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("ProjectInfo", []string{CustomerID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	ProjectInfos := []ProjectInfo{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var ProjectInfo ProjectInfo
+		if err := json.Unmarshal(response.Value, &ProjectInfo); err != nil {
+			return nil, fmt.Errorf("unmarshal project error, %s", err)
+		}
+		ProjectInfos = append(ProjectInfos, ProjectInfo)
+	}
+	return ProjectInfos, nil
+}
+
+// 解析项目信息参数
+// args: 客户编号, 项目名称, 项目编号, 业务类型, 所属行业, 批复下达日, 审批是否通过, 是否成立有限合伙人, 是否有自有资金投资, 持有债券金额, 被投资企业类型
+func parseProjectInfo(args []string) (ProjectInfo, error) {
+	var ProjectInfo ProjectInfo
+	var err error
+	ProjectInfo.ObjectType = docTypeProject
+
+	// 1.检查参数的个数
+	if len(args) != 11 {
+		return ProjectInfo, newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 11")
+	}
+
+	// 2.验证参数的正确性
+	ProjectInfo.Customer = args[0]
+	if ProjectInfo.Customer == "" {
+		return ProjectInfo, newError(ErrInvalidArgument, "id", "CustomerID can not be empty.")
+	}
+	ProjectInfo.ProjectName = args[1]
+	ProjectInfo.ProjectID = args[2]
+	if ProjectInfo.ProjectID == "" {
+		return ProjectInfo, newError(ErrInvalidArgument, "projectId", "ProjectID can not be empty.")
+	}
+	ProjectInfo.ProjectType = args[3]
+	ProjectInfo.ProjectTrade = args[4]
+	if ProjectInfo.ProjectDate, err = parseDate("projectDate", args[5]); err != nil {
+		return ProjectInfo, err
+	}
+	if ProjectInfo.ProjectApprove, err = parseBool("projectApprove", args[6]); err != nil {
+		return ProjectInfo, err
+	}
+	if ProjectInfo.ProjectPart, err = parseBool("projectPart", args[7]); err != nil {
+		return ProjectInfo, err
+	}
+	if ProjectInfo.ProjectInvest, err = parseBool("projectInvest", args[8]); err != nil {
+		return ProjectInfo, err
+	}
+	if ProjectInfo.ProjectMoney, err = parseMoney("projectMoney", args[9]); err != nil {
+		return ProjectInfo, err
+	}
+	ProjectInfo.ProjectCompanyType = args[10]
+
+	return ProjectInfo, nil
+}
+
+// 添加项目信息
+func (a *AssertsManageCC) addProjectInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	ProjectInfo, err := parseProjectInfo(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	CustomerID := ProjectInfo.Customer
+
+	// 3.验证数据是否存在
+	if _, err := requireOwnedCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	key, err := projectKey(stub, CustomerID, ProjectInfo.ProjectID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 同一客户下项目编号不能重复
+	if projectBytes, err := stub.GetState(key); err != nil {
+		return shim.Error(fmt.Sprintf("get stateDB error, %s", err))
+	} else if projectBytes != nil {
+		return shim.Error(newError(ErrProjectExists, "projectId", "Project already exist: %s", ProjectInfo.ProjectID).Error())
+	}
+
+	// 4.状态写入
+	// 记录写入该版本的组织
+	if ProjectInfo.MSPID, err = submitterMSPID(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 序列化对象
+	JSONasBytes, err := json.Marshal(ProjectInfo)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal project error, %s", err))
+	}
+	// 提交，写入 stateDB
+	if err := stub.PutState(key, JSONasBytes); err != nil {
+		return shim.Error(fmt.Sprintf("put stateDB error, %s", err))
+	}
+
+	// 5.发出链码事件，审批通过的项目发出 ProjectApproved
+	event := EventProjectAdded
+	if ProjectInfo.ProjectApprove {
+		event = EventProjectApproved
+	}
+	changed, err := changedFields(nil, &ProjectInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setEvent(stub, event, key, changed); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 查询客户名下所有项目
+func (a *AssertsManageCC) listProjectsByCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+	CustomerID := args[0]
+	if _, err := requireCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ProjectInfos, err := listProjects(stub, CustomerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	jsonsAsBytes, err := json.Marshal(ProjectInfos)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonsAsBytes)
+}
+
+// 获取项目历史数据
+// args: 客户编号[, 项目编号[, 开始时间, 结束时间]]，项目编号为空时返回客户名下全部项目的历史
+func (a *AssertsManageCC) getHistoryProjectInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1, 2 or 4").Error())
+	}
+	if len(args) == 1 {
+		return historyProjectInfo(stub, args[0], "", allHistory())
+	}
+	page, err := parseHistoryArgs(args[2:], false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyProjectInfo(stub, args[0], args[1], page)
+}
+
+// 分页获取项目历史数据
+// args: 客户编号, 项目编号（为空时返回客户名下全部项目的历史）, 每页记录数, 书签[, 开始时间, 结束时间]
+func (a *AssertsManageCC) getHistoryProjectInfoWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 2 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 4 or 6").Error())
+	}
+	page, err := parseHistoryArgs(args[2:], true)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return historyProjectInfo(stub, args[0], args[1], page)
+}
+
+// 按游标获取项目历史数据
+func historyProjectInfo(stub shim.ChaincodeStubInterface, CustomerID, ProjectID string, page *historyPage) pb.Response {
+	if _, err := requireCustomer(stub, CustomerID); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var ProjectIDs []string
+	if ProjectID != "" {
+		ProjectIDs = append(ProjectIDs, ProjectID)
+	} else {
+		ProjectInfos, err := listProjects(stub, CustomerID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		for _, ProjectInfo := range ProjectInfos {
+			ProjectIDs = append(ProjectIDs, ProjectInfo.ProjectID)
+		}
+	}
+
+	HistoryProjectInfos := []HistoryProjectInfo{}
+	for _, ProjectID := range ProjectIDs {
+		key, err := projectKey(stub, CustomerID, ProjectID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		histories, err := getHistoryForProject(stub, key, page)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		HistoryProjectInfos = append(HistoryProjectInfos, histories...)
+		if page.more {
+			break
+		}
+	}
+	jsonsAsBytes, err := page.result(HistoryProjectInfos)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonsAsBytes)
+}
+
+// 获取单个项目的历史数据
+func getHistoryForProject(stub shim.ChaincodeStubInterface, key string, page *historyPage) ([]HistoryProjectInfo, error) {
+	// 用 GetHistoryForKey 返回一个可以迭代的对象
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	HistoryProjectInfos := []HistoryProjectInfo{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		txTime := historyTime(response)
+		if !page.inRange(txTime) {
+			continue
+		}
+		if !page.take() {
+			if page.more {
+				break
+			}
+			continue
+		}
+		var HistoryProjectInfo HistoryProjectInfo
+		HistoryProjectInfo.TxID = response.TxId
+		HistoryProjectInfo.Time = txTime.Format(historyTimeLayout)
+		HistoryProjectInfo.IsDelete = response.IsDelete
+		// 删除操作没有数据，projectInfo 为 null
+		if !response.IsDelete {
+			HistoryProjectInfo.ProjectInfo = new(ProjectInfo)
+			if err := json.Unmarshal(response.Value, HistoryProjectInfo.ProjectInfo); err != nil {
+				return nil, fmt.Errorf("unmarshal project history error, tx %s, %s", response.TxId, err)
+			}
+			HistoryProjectInfo.MSPID = HistoryProjectInfo.ProjectInfo.MSPID
+		}
+		HistoryProjectInfos = append(HistoryProjectInfos, HistoryProjectInfo)
+	}
+	return HistoryProjectInfos, nil
+}
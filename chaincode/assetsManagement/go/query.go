@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// =======Rich queries =========================================================================
+// 富查询只在 CouchDB 作为状态数据库时可用，所需索引随链码打包在
+// META-INF/statedb/couchdb/indexes 目录下：
+//   indexCapital.json       docType, money.amount
+//   indexProjectTrade.json  docType, projectTrade, projectApprove, projectDate
+// 富查询结果在背书和提交之间可能发生变化（幻读），只用于查询，不要在写交易中使用。
+// 每个查询都有对应的 WithPagination 分页版本，参数末尾追加每页记录数和书签。
+// ============================================================================================
+
+// 按所属行业、审批结果和批复下达日区间查询项目
+// args: 所属行业, 审批是否通过, 开始日期, 结束日期；参数为空字符串时不作为查询条件
+func (a *AssertsManageCC) queryProjects(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 4").Error())
+	}
+	queryString, err := buildProjectsQuery(args[0], args[1], args[2], args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// 分页查询项目
+// args: 所属行业, 审批是否通过, 开始日期, 结束日期, 每页记录数, 书签
+func (a *AssertsManageCC) queryProjectsWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 6").Error())
+	}
+	queryString, err := buildProjectsQuery(args[0], args[1], args[2], args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryWithPagination(stub, queryString, args[4], args[5])
+}
+
+// 生成项目查询语句
+func buildProjectsQuery(trade, approved, from, to string) (string, error) {
+	selector := map[string]interface{}{"docType": docTypeProject}
+	if trade != "" {
+		selector["projectTrade"] = trade
+	}
+	if approved != "" {
+		projectApprove, err := parseBool("projectApprove", approved)
+		if err != nil {
+			return "", err
+		}
+		selector["projectApprove"] = projectApprove
+	}
+	dateRange := map[string]interface{}{}
+	if from != "" {
+		fromDate, err := parseDate("from", from)
+		if err != nil {
+			return "", err
+		}
+		dateRange["$gte"] = fromDate
+	}
+	if to != "" {
+		toDate, err := parseDate("to", to)
+		if err != nil {
+			return "", err
+		}
+		dateRange["$lte"] = toDate
+	}
+	if len(dateRange) > 0 {
+		selector["projectDate"] = dateRange
+	}
+
+	// CouchDB 的 JSON 索引只有在查询条件包含索引的全部字段时才能使用，
+	// 部分条件为空时不指定索引，由 CouchDB 选择
+	query := map[string]interface{}{"selector": selector}
+	if trade != "" && approved != "" && len(dateRange) > 0 {
+		query["use_index"] = []string{"_design/indexProjectTradeDoc", "indexProjectTrade"}
+	}
+	queryString, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return string(queryString), nil
+}
+
+// 查询注册资本高于指定金额的客户
+// args: 金额[, 币种]；不指定币种时不限制币种
+func (a *AssertsManageCC) queryCustomersByCapital(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1 or 2").Error())
+	}
+	currency := ""
+	if len(args) == 2 {
+		currency = args[1]
+	}
+	queryString, err := buildCapitalQuery(args[0], currency)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// 分页查询注册资本高于指定金额的客户
+// args: 金额, 币种（为空时不限制币种）, 每页记录数, 书签
+func (a *AssertsManageCC) queryCustomersByCapitalWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 4").Error())
+	}
+	queryString, err := buildCapitalQuery(args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryWithPagination(stub, queryString, args[2], args[3])
+}
+
+// 生成注册资本查询语句
+func buildCapitalQuery(capital, currency string) (string, error) {
+	if currency != "" {
+		capital += " " + currency
+	}
+	minCapital, err := parseMoney("minCapital", capital)
+	if err != nil {
+		return "", err
+	}
+	// json.Number 序列化为数字，CouchDB 按数值比较
+	selector := map[string]interface{}{
+		"docType":      docTypeCustomer,
+		"money.amount": map[string]interface{}{"$gt": minCapital.Amount},
+	}
+	if currency != "" {
+		selector["money.currency"] = minCapital.Currency
+	}
+
+	queryString, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/indexCapitalDoc", "indexCapital"},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(queryString), nil
+}
+
+// 自定义富查询，查询语句按 CouchDB 语法原样执行
+// args: 查询语句，如 {"selector":{"docType":"collateral","status":"pledged"}}
+func (a *AssertsManageCC) queryAssets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 1").Error())
+	}
+
+	queryResults, err := getQueryResultForQueryString(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// 分页执行自定义富查询
+// args: 查询语句, 每页记录数, 书签
+func (a *AssertsManageCC) queryAssetsWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(newError(ErrInvalidArgument, "", "Incorrect number of arguments. Expecting 3").Error())
+	}
+	return queryWithPagination(stub, args[0], args[1], args[2])
+}
+
+// 解析分页参数并执行分页富查询
+func queryWithPagination(stub shim.ChaincodeStubInterface, queryString, pageSizeArg, bookmark string) pb.Response {
+	pageSize, err := parsePageSize(pageSizeArg)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryResults, err := getQueryResultForQueryStringWithPagination(stub, queryString, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// 执行富查询，返回 [{"Key":..., "Record":...}] 形式的 JSON 数组
+func getQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString string) ([]byte, error) {
+	fmt.Printf("- getQueryResultForQueryString queryString:\n%s\n", queryString)
+
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructQueryResponseFromIterator(resultsIterator)
+}
+
+// 把查询结果迭代器中的记录写成 JSON 数组
+func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]byte, error) {
+	// buffer is a JSON array containing QueryRecords
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+
+	bArrayMemberAlreadyWritten := false
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		// Add a comma before array members, suppress it for the first array member
+		if bArrayMemberAlreadyWritten == true {
+			buffer.WriteString(",")
+		}
+		// 组合键中含有 U+0000 分隔符，需要转义后才能写入 JSON
+		keyAsBytes, err := json.Marshal(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString("{\"Key\":")
+		buffer.Write(keyAsBytes)
+
+		buffer.WriteString(", \"Record\":")
+		// Record is a JSON object, so we write as-is
+		buffer.WriteString(string(queryResponse.Value))
+		buffer.WriteString("}")
+		bArrayMemberAlreadyWritten = true
+	}
+	buffer.WriteString("]")
+
+	return buffer.Bytes(), nil
+}
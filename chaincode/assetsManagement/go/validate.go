@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Money 金额
+// Amount 保留十进制原文，序列化为 JSON 数字，下游可以直接做运算和大小比较
+type Money struct {
+	Amount   json.Number `json:"amount"`   //金额
+	Currency string      `json:"currency"` //币种，ISO 4217 代码
+}
+
+// 默认币种
+const defaultCurrency = "CNY"
+
+var (
+	amountPattern   = regexp.MustCompile(`^(0|[1-9][0-9]*)(\.[0-9]{1,4})?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// 解析金额，格式为 "金额[ 币种]"，如 "1000000.00 CNY"，不写币种时默认为人民币
+func parseMoney(field, value string) (Money, error) {
+	parts := strings.Fields(value)
+	if len(parts) == 0 || len(parts) > 2 {
+		return Money{}, newError(ErrInvalidAmount, field, "%s must be in the form \"<amount> [currency]\"", field)
+	}
+	if !amountPattern.MatchString(parts[0]) {
+		return Money{}, newError(ErrInvalidAmount, field, "%s is not a valid decimal amount: %s", field, parts[0])
+	}
+	currency := defaultCurrency
+	if len(parts) == 2 {
+		currency = strings.ToUpper(parts[1])
+		if !currencyPattern.MatchString(currency) {
+			return Money{}, newError(ErrInvalidCurrency, field, "%s has invalid currency code: %s", field, parts[1])
+		}
+	}
+	return Money{Amount: json.Number(parts[0]), Currency: currency}, nil
+}
+
+// 日期格式 ISO-8601
+const dateLayout = "2006-01-02"
+
+// 解析日期，返回规范化后的 ISO-8601 日期字符串
+func parseDate(field, value string) (string, error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return "", newError(ErrInvalidDate, field, "%s must be an ISO-8601 date (YYYY-MM-DD): %s", field, value)
+	}
+	return t.Format(dateLayout), nil
+}
+
+// 解析是否类字段
+func parseBool(field, value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "y", "1", "是":
+		return true, nil
+	case "false", "no", "n", "0", "否":
+		return false, nil
+	}
+	return false, newError(ErrInvalidBoolean, field, "%s must be a boolean: %s", field, value)
+}
+
+// 统一社会信用代码字符集及各位加权因子（GB 32100-2015）
+const creditCodeCharset = "0123456789ABCDEFGHJKLMNPQRTUWXY"
+
+var creditCodeWeights = []int{1, 3, 9, 27, 19, 26, 16, 17, 20, 29, 25, 13, 8, 24, 10, 30, 28}
+
+// 校验统一社会信用代码：18 位，字符集合法且第 18 位校验码正确
+func validateCreditCode(field, code string) error {
+	if len(code) != 18 {
+		return newError(ErrInvalidCreditCode, field, "%s must be 18 characters long", field)
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		n := strings.IndexByte(creditCodeCharset, code[i])
+		if n < 0 {
+			return newError(ErrInvalidCreditCode, field, "%s contains invalid character %q", field, code[i])
+		}
+		sum += n * creditCodeWeights[i]
+	}
+	check := (31 - sum%31) % 31
+	if code[17] != creditCodeCharset[check] {
+		return newError(ErrInvalidCreditCode, field, "%s has invalid check digit", field)
+	}
+	return nil
+}
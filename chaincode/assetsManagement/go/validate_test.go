@@ -0,0 +1,126 @@
+package main
+
+import "testing"
+
+// 返回结构化错误的错误码，err 为 nil 时返回空
+func errorCode(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	if e, ok := err.(*ccError); ok {
+		return e.Code
+	}
+	return "unstructured"
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		amount   string
+		currency string
+		code     ErrorCode
+	}{
+		{"1000000.00", "1000000.00", "CNY", ""},
+		{"0", "0", "CNY", ""},
+		{"12.3456 usd", "12.3456", "USD", ""},
+		{"  500  EUR ", "500", "EUR", ""},
+		{"", "", "", ErrInvalidAmount},
+		{"1 CNY extra", "", "", ErrInvalidAmount},
+		{"012", "", "", ErrInvalidAmount},
+		{"1.23456", "", "", ErrInvalidAmount},
+		{"-1", "", "", ErrInvalidAmount},
+		{"1e6", "", "", ErrInvalidAmount},
+		{"1,000", "", "", ErrInvalidAmount},
+		{"100 RMB1", "", "", ErrInvalidCurrency},
+		{"100 CN", "", "", ErrInvalidCurrency},
+	}
+	for _, tt := range tests {
+		money, err := parseMoney("capital", tt.value)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("parseMoney(%q) error = %v, want code %q", tt.value, err, tt.code)
+			continue
+		}
+		if err == nil && (string(money.Amount) != tt.amount || money.Currency != tt.currency) {
+			t.Errorf("parseMoney(%q) = %s %s, want %s %s", tt.value, money.Amount, money.Currency, tt.amount, tt.currency)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		code  ErrorCode
+	}{
+		{"2020-06-30", "2020-06-30", ""},
+		{" 2020-02-29 ", "2020-02-29", ""},
+		{"2019-02-29", "", ErrInvalidDate},
+		{"2020-6-30", "", ErrInvalidDate},
+		{"2020/06/30", "", ErrInvalidDate},
+		{"20200630", "", ErrInvalidDate},
+		{"", "", ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		got, err := parseDate("date", tt.value)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("parseDate(%q) error = %v, want code %q", tt.value, err, tt.code)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+		code  ErrorCode
+	}{
+		{"true", true, ""},
+		{"TRUE", true, ""},
+		{" yes ", true, ""},
+		{"1", true, ""},
+		{"是", true, ""},
+		{"false", false, ""},
+		{"N", false, ""},
+		{"0", false, ""},
+		{"否", false, ""},
+		{"", false, ErrInvalidBoolean},
+		{"2", false, ErrInvalidBoolean},
+		{"ok", false, ErrInvalidBoolean},
+	}
+	for _, tt := range tests {
+		got, err := parseBool("flag", tt.value)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("parseBool(%q) error = %v, want code %q", tt.value, err, tt.code)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBool(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCreditCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want ErrorCode
+	}{
+		{"valid", "91350100M000100Y43", ""},
+		{"valid numeric check digit", "91110000600037341L", ""},
+		{"bad checksum", "91350100M000100Y44", ErrInvalidCreditCode},
+		{"lowercase", "91350100m000100y43", ErrInvalidCreditCode},
+		{"invalid character", "91350100I000100Y43", ErrInvalidCreditCode},
+		{"too short", "91350100M000100Y4", ErrInvalidCreditCode},
+		{"too long", "91350100M000100Y430", ErrInvalidCreditCode},
+		{"empty", "", ErrInvalidCreditCode},
+	}
+	for _, tt := range tests {
+		if got := errorCode(validateCreditCode("code", tt.code)); got != tt.want {
+			t.Errorf("%s: validateCreditCode(%q) code = %q, want %q", tt.name, tt.code, got, tt.want)
+		}
+	}
+}
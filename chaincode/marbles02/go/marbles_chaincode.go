@@ -31,11 +31,16 @@ under the License.
 // peer chaincode query -C myc1 -n marbles -c '{"Args":["readMarble","marble1"]}'
 // peer chaincode query -C myc1 -n marbles -c '{"Args":["getMarblesByRange","marble1","marble3"]}'
 // peer chaincode query -C myc1 -n marbles -c '{"Args":["getHistoryForMarble","marble1"]}'
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["getMarblesByRangeWithPagination","marble1","marble3","3",""]}'
 
 // Rich Query (Only supported if CouchDB is used as state database):
 //   peer chaincode query -C myc1 -n marbles -c '{"Args":["queryMarblesByOwner","tom"]}'
 //   peer chaincode query -C myc1 -n marbles -c '{"Args":["queryMarbles","{\"selector\":{\"owner\":\"tom\"}}"]}'
 
+// Rich Query with Pagination (Only supported if CouchDB is used as state database):
+//   peer chaincode query -C myc1 -n marbles -c '{"Args":["queryMarblesByOwnerWithPagination","tom","3",""]}'
+//   peer chaincode query -C myc1 -n marbles -c '{"Args":["queryMarblesWithPagination","{\"selector\":{\"owner\":\"tom\"}}","3",""]}'
+
 // INDEXES TO SUPPORT COUCHDB RICH QUERIES
 //
 // Indexes in CouchDB are required in order to make JSON queries efficient and are required for
@@ -164,6 +169,12 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.getHistoryForMarble(stub, args)
 	} else if function == "getMarblesByRange" { //get marbles based on range query
 		return t.getMarblesByRange(stub, args)
+	} else if function == "getMarblesByRangeWithPagination" { //get a page of marbles based on range query
+		return t.getMarblesByRangeWithPagination(stub, args)
+	} else if function == "queryMarblesByOwnerWithPagination" { //find a page of marbles for owner X using rich query
+		return t.queryMarblesByOwnerWithPagination(stub, args)
+	} else if function == "queryMarblesWithPagination" { //find a page of marbles based on an ad hoc rich query
+		return t.queryMarblesWithPagination(stub, args)
 	}
 
 	fmt.Println("invoke did not find func: " + function) //error
@@ -386,38 +397,62 @@ func (t *SimpleChaincode) getMarblesByRange(stub shim.ChaincodeStubInterface, ar
 	}
 	defer resultsIterator.Close()
 
-	// buffer is a JSON array containing QueryResults
-	var buffer bytes.Buffer
-	buffer.WriteString("[")
-
-	bArrayMemberAlreadyWritten := false
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(err.Error())
-		}
-		// Add a comma before array members, suppress it for the first array member
-		if bArrayMemberAlreadyWritten == true {
-			buffer.WriteString(",")
-		}
-		buffer.WriteString("{\"Key\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
-		buffer.WriteString("\"")
-
-		buffer.WriteString(", \"Record\":")
-		// Record is a JSON object, so we write as-is
-		buffer.WriteString(string(queryResponse.Value))
-		buffer.WriteString("}")
-		bArrayMemberAlreadyWritten = true
+	buffer, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	buffer.WriteString("]")
 
 	fmt.Printf("- getMarblesByRange queryResult:\n%s\n", buffer.String())
 
 	return shim.Success(buffer.Bytes())
 }
 
+// ==== Example: Pagination with Range Query ===============================================
+// getMarblesByRangeWithPagination performs a range query based on the start & end key,
+// page size and a bookmark.
+// The number of fetched records will be equal to or lesser than the page size.
+// Paginated range queries are only valid for read only transactions.
+// The bookmark returned with a page is passed back to fetch the next page;
+// an empty bookmark starts from the first record.
+// ===========================================================================================
+func (t *SimpleChaincode) getMarblesByRangeWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	//   0          1         2          3
+	// "startKey", "endKey", "pageSize", "bookmark"
+	if len(args) < 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4")
+	}
+
+	startKey := args[0]
+	endKey := args[1]
+	//return type of ParseInt is int64
+	pageSize, err := strconv.ParseInt(args[2], 10, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pageSize <= 0 {
+		return shim.Error("pageSize must be a positive integer")
+	}
+	bookmark := args[3]
+
+	resultsIterator, responseMetadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	buffer, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	bufferWithPaginationInfo := addPaginationMetadataToQueryResults(buffer, responseMetadata)
+
+	fmt.Printf("- getMarblesByRangeWithPagination queryResult:\n%s\n", bufferWithPaginationInfo.String())
+
+	return shim.Success(bufferWithPaginationInfo.Bytes())
+}
+
 // ==== Example: GetStateByPartialCompositeKey/RangeQuery =========================================
 // transferMarblesBasedOnColor will transfer marbles of a given color to a certain new owner.
 // Uses a GetStateByPartialCompositeKey (range query) against color~name 'index'.
@@ -554,7 +589,22 @@ func getQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString
 	}
 	defer resultsIterator.Close()
 
-	// buffer is a JSON array containing QueryRecords
+	buffer, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("- getQueryResultForQueryString queryResult:\n%s\n", buffer.String())
+
+	return buffer.Bytes(), nil
+}
+
+// ===========================================================================================
+// constructQueryResponseFromIterator constructs a JSON array containing query results from
+// a given result iterator
+// ===========================================================================================
+func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) (*bytes.Buffer, error) {
+	// buffer is a JSON array containing QueryResults
 	var buffer bytes.Buffer
 	buffer.WriteString("[")
 
@@ -581,9 +631,125 @@ func getQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString
 	}
 	buffer.WriteString("]")
 
-	fmt.Printf("- getQueryResultForQueryString queryResult:\n%s\n", buffer.String())
+	return &buffer, nil
+}
 
-	return buffer.Bytes(), nil
+// ===== Example: Pagination with Parameterized Rich Query =================================
+// queryMarblesByOwnerWithPagination queries for a page of marbles based on a passed in owner.
+// The number of fetched records will be equal to or lesser than the specified page size.
+// Paginated queries are only valid for read only transactions.
+// Only available on state databases that support rich query (e.g. CouchDB)
+// =========================================================================================
+func (t *SimpleChaincode) queryMarblesByOwnerWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	//   0       1           2
+	// "bob", "pageSize", "bookmark"
+	if len(args) < 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	owner := strings.ToLower(args[0])
+	//return type of ParseInt is int64
+	pageSize, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pageSize <= 0 {
+		return shim.Error("pageSize must be a positive integer")
+	}
+	bookmark := args[2]
+
+	queryString := fmt.Sprintf("{\"selector\":{\"docType\":\"marble\",\"owner\":\"%s\"}}", owner)
+
+	queryResults, err := getQueryResultForQueryStringWithPagination(stub, queryString, int32(pageSize), bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// ===== Example: Pagination with Ad hoc Rich Query ========================================
+// queryMarblesWithPagination uses a query string, page size and a bookmark to perform a query
+// for marbles. Query string matching state database syntax is passed in and executed as is.
+// The number of fetched records would be equal to or lesser than the specified page size.
+// Supports ad hoc queries that can be defined at runtime by the client.
+// If this is not desired, follow the queryMarblesForOwner example for parameterized queries.
+// Only available on state databases that support rich query (e.g. CouchDB)
+// Paginated queries are only valid for read only transactions.
+// =========================================================================================
+func (t *SimpleChaincode) queryMarblesWithPagination(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	//   0              1           2
+	// "queryString", "pageSize", "bookmark"
+	if len(args) < 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	queryString := args[0]
+	//return type of ParseInt is int64
+	pageSize, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pageSize <= 0 {
+		return shim.Error("pageSize must be a positive integer")
+	}
+	bookmark := args[2]
+
+	queryResults, err := getQueryResultForQueryStringWithPagination(stub, queryString, int32(pageSize), bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// =========================================================================================
+// getQueryResultForQueryStringWithPagination executes the passed in query string with
+// pagination info. Result set is built and returned as a byte array containing the JSON results.
+// =========================================================================================
+func getQueryResultForQueryStringWithPagination(stub shim.ChaincodeStubInterface, queryString string, pageSize int32, bookmark string) ([]byte, error) {
+
+	fmt.Printf("- getQueryResultForQueryString queryString:\n%s\n", queryString)
+
+	resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	buffer, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferWithPaginationInfo := addPaginationMetadataToQueryResults(buffer, responseMetadata)
+
+	fmt.Printf("- getQueryResultForQueryString queryResult:\n%s\n", bufferWithPaginationInfo.String())
+
+	return bufferWithPaginationInfo.Bytes(), nil
+}
+
+// ===========================================================================================
+// addPaginationMetadataToQueryResults wraps the JSON array of query results together with
+// the pagination metadata:
+// {"records":[...], "fetchedRecordsCount":n, "bookmark":"..."}
+// ===========================================================================================
+func addPaginationMetadataToQueryResults(buffer *bytes.Buffer, responseMetadata *pb.QueryResponseMetadata) *bytes.Buffer {
+
+	var result bytes.Buffer
+	result.WriteString("{\"records\":")
+	result.Write(buffer.Bytes())
+
+	result.WriteString(", \"fetchedRecordsCount\":")
+	result.WriteString(strconv.FormatInt(int64(responseMetadata.FetchedRecordsCount), 10))
+
+	// the bookmark may be a state key, encode it as a JSON string
+	bookmarkAsBytes, _ := json.Marshal(responseMetadata.Bookmark)
+	result.WriteString(", \"bookmark\":")
+	result.Write(bookmarkAsBytes)
+	result.WriteString("}")
+
+	return &result
 }
 
 func (t *SimpleChaincode) getHistoryForMarble(stub shim.ChaincodeStubInterface, args []string) pb.Response {
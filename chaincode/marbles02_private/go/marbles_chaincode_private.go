@@ -419,6 +419,8 @@ func (t *SimpleChaincode) transferMarble(stub shim.ChaincodeStubInterface, args
 // invalidated by the committing peers if the result set has changed between endorsement
 // time and commit time.
 // Therefore, range queries are a safe option for performing update transactions based on query results.
+// Note that the shim offers no paginated variant of GetPrivateDataByRange, so unlike the
+// marbles02 sample there is no getMarblesByRangeWithPagination here.
 // ===========================================================================================
 func (t *SimpleChaincode) getMarblesByRange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
@@ -538,6 +540,8 @@ func (t *SimpleChaincode) transferMarblesBasedOnColor(stub shim.ChaincodeStubInt
 // Therefore, rich queries should not be used in update transactions, unless the
 // application handles the possibility of result set changes between endorsement and commit time.
 // Rich queries can be used for point-in-time queries against a peer.
+// Private data queries (GetPrivateDataQueryResult) do not support pagination, so the
+// WithPagination variants of the marbles02 sample are not available for private marbles.
 // ============================================================================================
 
 // ===== Example: Parameterized rich query =================================================